@@ -43,6 +43,11 @@ type Config struct {
 		MaxHeaderBytes       int
 		StaticFilePath       string
 		UrlRewrite           bool
+		UrlRewriteFile       string
+		AdminAddr            string
+		TrailingSlash        string
+		CaseInsensitive      bool
+		CleanPath            bool
 	}
 	Database struct {
 		Name     string
@@ -76,6 +81,11 @@ type fileConfig struct {
 			MaxHeaderBytes       int    `json:"MaxHeaderBytes"`
 			StaticFilePath       string `json:"StaticFilePath"`
 			UrlRewrite           bool   `json:"UrlRewrite"`
+			UrlRewriteFile       string `json:"UrlRewriteFile"`
+			AdminAddr            string `json:"AdminAddr"`
+			TrailingSlash        string `json:"TrailingSlash"`
+			CaseInsensitive      bool   `json:"CaseInsensitive"`
+			CleanPath            bool   `json:"CleanPath"`
 		}
 		Database struct {
 			Name     string `json:"Name"`
@@ -106,6 +116,11 @@ type fileConfig struct {
 			MaxHeaderBytes       int    `json:"MaxHeaderBytes"`
 			StaticFilePath       string `json:"StaticFilePath"`
 			UrlRewrite           bool   `json:"UrlRewrite"`
+			UrlRewriteFile       string `json:"UrlRewriteFile"`
+			AdminAddr            string `json:"AdminAddr"`
+			TrailingSlash        string `json:"TrailingSlash"`
+			CaseInsensitive      bool   `json:"CaseInsensitive"`
+			CleanPath            bool   `json:"CleanPath"`
 		}
 		Database struct {
 			Name     string `json:"Name"`
@@ -175,6 +190,11 @@ func NewConfig(env string) (*Config, error) {
 			retnConfig.Site.MaxHeaderBytes = config.Prod.Site.MaxHeaderBytes
 			retnConfig.Site.StaticFilePath = config.Prod.Site.StaticFilePath
 			retnConfig.Site.UrlRewrite = config.Prod.Site.UrlRewrite
+			retnConfig.Site.UrlRewriteFile = config.Prod.Site.UrlRewriteFile
+			retnConfig.Site.AdminAddr = config.Prod.Site.AdminAddr
+			retnConfig.Site.TrailingSlash = config.Prod.Site.TrailingSlash
+			retnConfig.Site.CaseInsensitive = config.Prod.Site.CaseInsensitive
+			retnConfig.Site.CleanPath = config.Prod.Site.CleanPath
 
 			retnConfig.Database.Name = config.Prod.Database.Name
 			retnConfig.Database.Host = config.Prod.Database.Host
@@ -202,6 +222,11 @@ func NewConfig(env string) (*Config, error) {
 			retnConfig.Site.MaxHeaderBytes = config.Dev.Site.MaxHeaderBytes
 			retnConfig.Site.StaticFilePath = config.Dev.Site.StaticFilePath
 			retnConfig.Site.UrlRewrite = config.Dev.Site.UrlRewrite
+			retnConfig.Site.UrlRewriteFile = config.Dev.Site.UrlRewriteFile
+			retnConfig.Site.AdminAddr = config.Dev.Site.AdminAddr
+			retnConfig.Site.TrailingSlash = config.Dev.Site.TrailingSlash
+			retnConfig.Site.CaseInsensitive = config.Dev.Site.CaseInsensitive
+			retnConfig.Site.CleanPath = config.Dev.Site.CleanPath
 
 			retnConfig.Database.Name = config.Dev.Database.Name
 			retnConfig.Database.Host = config.Dev.Database.Host
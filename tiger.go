@@ -20,7 +20,9 @@
 package main
 
 import (	
+	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"log"	
 	"strconv"	
 	"net/http"
@@ -38,13 +40,18 @@ import (
 )
 
 func main() {
+	var flagVar string
+	var openApiFile string
+	var assetManifestFile string
+	flag.StringVar(&flagVar, "env", "", "set environment setting to Dev,Qa,Prod")
+	flag.StringVar(&openApiFile, "openapi", "", "export the OpenAPI 3 document of the registered url mapping to this file and exit")
+	flag.StringVar(&assetManifestFile, "manifest", "", "export the fingerprinted static file manifest as json to this file and exit")
+	flag.Parse()
+
 	var env = ""
 	env = os.Getenv("env")
 	if env == "" {
 		//try commandline option
-		var flagVar string
-		flag.StringVar(&flagVar, "env", "", "set environment setting to Dev,Qa,Prod")
-		flag.Parse()
 		env = flagVar
 	}
 	c, err := config.NewConfig(env)
@@ -94,26 +101,45 @@ func main() {
 	}
 	wg.Wait()
 	
-	actualMux := httpUtil.NewServeMux(c, db)
-		
-	var mux *http.ServeMux	
-	if !c.Site.UrlRewrite {
-		mux = actualMux
-	} else {
-		muxWrapper := http.NewServeMux()
-		muxWrapper.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {			
-								logUtil.DebugPrintln("muxWrapper incoming url: "+r.URL.Path)
-								r.URL.Path = httpUtil.GetRewriteUrlTarget(r.URL.Path)
-								logUtil.DebugPrintln("muxWrapper outgoing url: "+r.URL.Path)
-								actualMux.ServeHTTP(w, r)
-							})		
-		mux = muxWrapper
+	if openApiFile != "" {
+		b, err := httpUtil.GenerateOpenAPI(c.Site.Name, httpUtil.OpenAPIVersion)
+		if err == nil {
+			err = ioutil.WriteFile(openApiFile, b, 0644)
+		}
+		if err != nil {
+			log.Printf("error export OpenAPI document: %v", err)
+			return
+		}
+		log.Print("OpenAPI document exported to " + openApiFile)
+		return
 	}
-
+	
+	handler := httpUtil.NewHandler(c, db)
+	
+	if assetManifestFile != "" {
+		b, err := json.MarshalIndent(httpUtil.AssetManifest(), "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(assetManifestFile, b, 0644)
+		}
+		if err != nil {
+			log.Printf("error export asset manifest: %v", err)
+			return
+		}
+		log.Print("asset manifest exported to " + assetManifestFile)
+		return
+	}
+		
 	connClosed := make(chan string)
 	srv := &http.Server{
 		Addr : ":"+strconv.Itoa(c.Site.Port), 
-		Handler : mux,
+		Handler : handler,
+	}
+	var adminSrv *http.Server
+	if c.Site.AdminAddr != "" {
+		adminSrv = &http.Server{
+			Addr : c.Site.AdminAddr,
+			Handler : httpUtil.NewAdminServeMux(c, db),
+		}
 	}
 	srv.RegisterOnShutdown(func(){
 		log.Print("received an interrupt signal, server shutting down ...")
@@ -126,6 +152,11 @@ func main() {
 		<-sigint //block until interrupt signal is received
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Site.GracefulShutdownSec)*time.Second)
 		defer cancel()		
+		if adminSrv != nil {
+			if err := adminSrv.Shutdown(ctx); err != nil {
+				log.Printf("error shutdown admin server: %v", err)
+			}
+		}
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("error shutdown server: %v", err)
 		}
@@ -134,6 +165,12 @@ func main() {
 		log.Print("server starting up ...")
 		srv.ListenAndServe()
 	}()
+	if adminSrv != nil {
+		go func(){
+			log.Print("admin server starting up on " + c.Site.AdminAddr + " ...")
+			adminSrv.ListenAndServe()
+		}()
+	}
 	go func(){
 		client := &http.Client{Timeout: time.Duration(c.Site.CheckAliveTimeoutSec)*time.Second}	
 		resp, err := client.Get("http://"+c.Site.Url+":"+strconv.Itoa(c.Site.Port))		
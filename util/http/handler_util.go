@@ -23,6 +23,11 @@ func StartupInit(c *config.Config, db *sql.DB) {
 // 	AddCustomErrorPage(http.StatusNotFound, "templates/errors/404Error.html", nil)
 // 	AddCustomErrorPage(http.StatusNotFound, "templates/errors/404Error.html", map[string]string{ "custom header" : "can see?" })
 // 	AddCustomErrorPage(http.StatusInternalServerError, "templates/errors/500Error.html", nil)
+//
+// the page is written with the error status code. for a page showing the status, message, request id, path and localized text use a template rendered with ErrorPageData,
+// the static page of the same code is the fallback if rendering fail. call SetErrorText(i18nUtil.GetMsg, "en") for {{.T "key"}} to use the properties files.
+// 	AddCustomErrorTemplate(http.StatusNotFound, "templates/errors/error.html", nil)
+// 	AddCustomErrorTemplate(http.StatusInternalServerError, "templates/errors/error.html", nil)
 func RegisterCustomErrorPages(c *config.Config, db *sql.DB) {
 	log.Print("register custom error pages ...")
 	//////// add application specific logic below ////////
@@ -43,16 +48,29 @@ func RegisterCustomErrorPages(c *config.Config, db *sql.DB) {
 //if not passed would default to http.MethodGet
 //
 //for support of Path Param url mapping like /{placeholder} or /:placeholder need to implement the httpUtil.PathTokenHandler interface before registering
-//please call AddHandlerPathParam(urlMapping string, pathTokenHandler PathTokenHandler, httpVerb ...string)
+//please call AddHandlerPathParam(urlMapping string, pathTokenHandler PathTokenHandler, httpVerb ...string), or AddHandlerPlaceholder(...) for a http.Handler calling PathParam(r, "placeholder")
 //
 //for support of more complicated url mapping like regular expression please call func AddHandlerRegEx(urlMapping string, handler http.Handler, httpVerb ...string) instead. Refer to go regexp package for the re syntax
 //
 //for support of chaining of handlers to call them one by one sequentially need to implement ChainNextHandler and/or ChainPathTokenHandler before registering
-//please call their equivalent func AddChainHandler(...), AddChainHandlerRegEx(...), AddChainHandlerPathParam(...)
+//please call their equivalent func AddChainHandler(...), AddChainHandlerRegEx(...), AddChainHandlerPathParam(...), AddChainHandlerPlaceholder(...)
+//
+//how the url path is matched is set by the json attributes TrailingSlash, CaseInsensitive and CleanPath in config.json
+//
+//for code around every handler please call Use(...) or RouteGroup.Use(...) with a Middleware, or put MiddlewareAdapter(...) in a chain. pass data between them by a StateKey
+//
+//for handler returning error instead of writing it please use ErrorHandlerFunc or ChainErrorAdapter(...), see WriteError and WriteProblem. a panic is recovered by Recovery and counted by ErrorStats
+//
+//for host based routing or url mapping sharing the same prefix please call Host(hostPattern string) or Group(prefix string) then the same Add* func on the RouteGroup
+//
+//for content negotiation and api versioning pass Negotiate(...) as the handler, to call several backends concurrently put a FanOut in a chain
+//
+//for static files please call AddStaticMount(...), for forwarding to other services AddProxyRoute(...). ListRoutes(), RemoveHandler(...) and ReplaceHandler(...) work at runtime, SetRouteDoc(...) describe a url mapping in the OpenAPI document
 //
 //for support of url rewriting please ensure the json attribute for UrlRewrite is set to true in config.json. due to performance concern this feature must be explicitly enabled. please call AddRewriteUrl(sourceUrl string, targetUrl string) where sourceUrl can be normal, path param, regular expression.
 //for path param /{placeholder} or /:placeholder to be carried over to targetUrl ensure the SAME placeholder is placed in targetUrl.
 //for regex matched to be carried over to targetUrl, please enclose in parenthesis on sourceUrl and then use $1 , $2 on targetUrl.
+//for mod_rewrite like rules please call AddRewriteRule(RewriteRule{...}) or set the json attribute UrlRewriteFile in config.json, see LoadRewriteFile
 //
 // 	Example
 // 	AddHandlerRegEx("/hello1/.*/12[34]$", &logic1.ApiHandler{Db: db}, http.MethodGet)
@@ -60,6 +78,7 @@ func RegisterCustomErrorPages(c *config.Config, db *sql.DB) {
 // 	AddHandler("/hello3", &logic2.ApiHandler{Config: c, Next: nil}, http.MethodGet)
 // 	AddHandler("/hello4", &logic2.LogicHandler{}, http.MethodGet, http.MethodPost)
 // 	AddHandlerPathParam("/hello5/:userId/test/{prodId}", &logic2.Logic2Handler{}, http.MethodGet, http.MethodPost)
+// 	AddHandlerPlaceholder("/hello11/{id:int}/files/{*path}", &logic2.Logic3Handler{}, http.MethodGet)
 //
 // 	firstChain := []ChainNextHandler{
 //		&logic3.Api1Handler{Config: c},
@@ -91,6 +110,19 @@ func RegisterCustomErrorPages(c *config.Config, db *sql.DB) {
 //	}
 //	AddChainHandler("/hello10", fifthChain, http.MethodGet)
 //
+//	sixthChain := []ChainNextHandler{
+//		MiddlewareAdapter(logic3.Timing),
+//		&logic3.Api1Handler{Config: c},
+//		&logic3.Api2Handler{Config: c},
+//	}
+//	AddChainHandler("/hello13", sixthChain, http.MethodGet)
+//	Group("/limited").Use(ChainNextAdapter(rateLimiter.NewTokenBucketHandler(60, 30, 30))).AddHandler("/hello14", &logic2.LogicHandler{}, http.MethodGet)
+//
+//	tenant := Host("{tenant}.example.com")
+//	tenant.AddHandler("/hello12", &logic2.TenantHandler{}, http.MethodGet)
+//	tenant.Group("/api").AddHandlerPlaceholder("/user/{id:int}", &logic2.UserHandler{}, http.MethodGet)
+//	tenant.AddCustomErrorPage(http.StatusNotFound, "templates/errors/tenant404Error.html", nil)
+//
 //	AddRewriteUrl("/testhello4", "/hello4")
 //	AddRewriteUrl("/testhello5/haha/:userId/test/{prodId}", "/hello5/:userId/test/{prodId}")
 //	AddRewriteUrl("/testhello1/haha/(.*)/(12[34]$)", "/hello1/$1/$2")
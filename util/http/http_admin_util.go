@@ -0,0 +1,115 @@
+package httpUtil
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"tiger/config"
+	logUtil "tiger/util/log"
+)
+
+var onceAdmin sync.Once
+var onceAdminMux sync.Once
+var adminMux *http.ServeMux
+
+var adminRoutesTemplate = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html><head><title>routes</title></head><body>
+<h1>routes</h1>
+<form action="routes/match"><input name="method" value="GET" size="8"> <input name="url" placeholder="/path or http://host/path" size="60"> <input type="hidden" name="format" value="html"><input type="submit" value="match"></form>
+<table border="1" cellpadding="4">
+<tr><th>host</th><th>pattern</th><th>kind</th><th>http verb</th><th>handler</th><th>chain</th><th>middleware</th><th>name</th></tr>
+{{range .}}<tr><td>{{.Host}}</td><td>{{.Pattern}}</td><td>{{.Kind}}</td><td>{{range .HttpVerb}}{{.}} {{end}}</td><td>{{.Handler}}</td><td>{{range .Chain}}{{.}}<br>{{end}}</td><td>{{range .Middleware}}{{.}}<br>{{end}}</td><td>{{.Name}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+var adminMatchTemplate = template.Must(template.New("match").Parse(`<!DOCTYPE html>
+<html><head><title>route match</title></head><body>
+<h1>{{.Method}} {{.Url}}</h1>
+<p>host: {{.Host}}</p>
+{{range .Rewrite}}<p>{{if .Redirect}}redirect {{.Redirect}}{{else}}rewrite{{end}} {{.From}} to {{.To}} by {{.SourceUrl}} =&gt; {{.TargetUrl}} {{.Host}}</p>
+{{end}}<p>path: {{.Path}}</p>
+{{if .Redirect}}<p>redirect to {{.Redirect}}</p>
+{{end}}{{if .Matched}}<p>matched: {{.Route.Pattern}} ({{.Route.Kind}}) {{.Route.Host}} {{.Route.Handler}}{{range .Route.Chain}} {{.}}{{end}}</p>
+<p>http verb allowed: {{.VerbOk}}</p>
+{{range $key, $value := .PathParam}}<p>{{$key}} = {{$value}}</p>
+{{end}}{{else}}<p>no match, not found page will be served</p>{{end}}
+<p><a href="../routes?format=html">back</a></p>
+</body></html>`))
+
+// NewAdminServeMux to get a singleton http.ServeMux for the admin listener started on the json attribute AdminAddr in config.json.
+// built-in admin url are
+// 	/routes list all url mapping. ?format=json or ?format=html else decided by the Accept header
+// 	/routes/match?method=GET&url=/user/1 show which url mapping, path param and rewrite url a request would resolve to
+// 	/openapi.json OpenAPI 3 document of the default host url mapping, see GenerateOpenAPI
+// 	/assets.json url of the static files to their fingerprinted url, see AssetManifest
+// 	/errors recent and most frequent errors with count, first and last seen. ?format=json or ?format=html, see ErrorStats
+// 	/proxy health, requests in progress and ejection of the upstreams of the proxy routes. ?format=json or ?format=html, see ProxyStats
+// more admin url can be added by AddAdminHandler.
+func NewAdminServeMux(c *config.Config, db *sql.DB) *http.ServeMux {
+	onceAdmin.Do(func() { //singleton
+		logUtil.DebugPrint("admin serve mux first time init\n")
+		initAdminMux()
+		adminMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				io.WriteString(w, "I am admin!")
+				return
+			}
+			http.NotFound(w, r)
+		})
+		adminMux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+			writeAdmin(w, r, adminRoutesTemplate, ListRoutes())
+		})
+		adminMux.HandleFunc("/routes/match", func(w http.ResponseWriter, r *http.Request) {
+			method := r.URL.Query().Get("method")
+			if method == "" {
+				method = http.MethodGet
+			}
+			result, err := MatchRoute(strings.ToUpper(method), r.URL.Query().Get("url"), c.Site.UrlRewrite)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeAdmin(w, r, adminMatchTemplate, result)
+		})
+		adminMux.Handle("/openapi.json", OpenAPIHandler(c.Site.Name, OpenAPIVersion))
+		adminMux.Handle("/assets.json", AssetManifestHandler())
+		adminMux.Handle("/errors", ErrorStatsHandler())
+		adminMux.Handle("/proxy", ProxyStatsHandler())
+	})
+	return adminMux
+}
+
+// AddAdminHandler to add url mapping to handler on the admin listener. Direct url syntax as in http.ServeMux.
+func AddAdminHandler(urlMapping string, handler http.Handler) {
+	initAdminMux()
+	adminMux.Handle(urlMapping, handler)
+}
+
+func initAdminMux() {
+	onceAdminMux.Do(func() { //singleton
+		adminMux = http.NewServeMux()
+	})
+}
+
+// writeAdmin to write data as html using tpl if asked by ?format=html or the Accept header else as json.
+func writeAdmin(w http.ResponseWriter, r *http.Request, tpl *template.Template, data interface{}) {
+	format := r.URL.Query().Get("format")
+	if format == "html" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/html")) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tpl.Execute(w, data); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		log.Print(err)
+	}
+}
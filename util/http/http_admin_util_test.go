@@ -0,0 +1,102 @@
+package httpUtil
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type routeListTestHandler struct{}
+
+func (routeListTestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func TestListRoutes(t *testing.T) {
+	newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/list/a", routeListTestHandler{}, http.MethodGet, http.MethodPost)
+		AddHandler("/list/static/", textHandler("static"))
+		AddHandlerPlaceholder("/list/u/{id}", textHandler("u"))
+		AddChainHandler("/list/chain", []ChainNextHandler{chainNextFunc(nil), chainNextFunc(nil)})
+		Host("list.example.com").AddHandlerRegEx("^/list/r$", textHandler("r"))
+	})
+	if !SetRouteName("/list/a", "list-a") {
+		t.Fatal("SetRouteName /list/a = false")
+	}
+	want := map[string]RouteInfo{
+		"/list/a":                    {Pattern: "/list/a", Kind: "exact", HttpVerb: []string{"GET", "POST"}, Handler: "httpUtil.routeListTestHandler", Name: "list-a"},
+		"/list/static/":              {Pattern: "/list/static/", Kind: "glob"},
+		"/list/u/{id}":               {Pattern: "/list/u/{id}", Kind: "path-param"},
+		"/list/chain":                {Pattern: "/list/chain", Kind: "exact", ChainLength: 2},
+		"list.example.com ^/list/r$": {Host: "list.example.com", Pattern: "^/list/r$", Kind: "regex"},
+	}
+	found := 0
+	for _, route := range ListRoutes() {
+		key := strings.TrimSpace(route.Host + " " + route.Pattern)
+		expected, ok := want[key]
+		if !ok {
+			continue
+		}
+		found++
+		if route.Host != expected.Host || route.Kind != expected.Kind || route.ChainLength != expected.ChainLength || route.Name != expected.Name ||
+			(expected.Handler != "" && route.Handler != expected.Handler) || (expected.HttpVerb != nil && strings.Join(route.HttpVerb, ",") != strings.Join(expected.HttpVerb, ",")) {
+			t.Errorf("ListRoutes %s = %+v, want %+v", key, route, expected)
+		}
+	}
+	if found != len(want) {
+		t.Errorf("ListRoutes found %d of the %d url mapping", found, len(want))
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/match/u/{id:int}", textHandler("u"), http.MethodPost)
+		Host("{tenant}.match.test").AddHandler("/match/t", textHandler("t"))
+	})
+	for rawUrl, want := range map[string]string{
+		"/match/u/7":                     "/match/u/{id:int} id=7 verb=false",
+		"http://acme.match.test/match/t": "/match/t tenant=acme verb=true",
+		"/match/u/x":                     "",
+	} {
+		result, err := MatchRoute(http.MethodGet, rawUrl, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if result.Matched {
+			got = result.Route.Pattern
+			for _, name := range []string{"id", "tenant"} {
+				if value, found := result.PathParam[name]; found {
+					got += " " + name + "=" + value
+				}
+			}
+			if result.VerbOk {
+				got += " verb=true"
+			} else {
+				got += " verb=false"
+			}
+		}
+		if got != want {
+			t.Errorf("MatchRoute(GET, %s) = %q, want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/admin/route", textHandler("route"))
+	})
+	admin := NewAdminServeMux(newTestConfig(), nil)
+	w := serveTest(admin, http.MethodGet, "/routes")
+	var routes []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &routes); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /routes = %q %v, want json", w.Header().Get("Content-Type"), err)
+	}
+	if w := serveTest(admin, http.MethodGet, "/routes?format=html"); !strings.Contains(w.Body.String(), "<td>/admin/route</td>") {
+		t.Errorf("GET /routes?format=html does not list /admin/route: %s", w.Body.String())
+	}
+	w = serveTest(admin, http.MethodGet, "/routes/match?url=/admin/route")
+	var result RouteMatch
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || !result.Matched || result.Route.Pattern != "/admin/route" {
+		t.Errorf("GET /routes/match?url=/admin/route = %s, want a match", w.Body.String())
+	}
+}
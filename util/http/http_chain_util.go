@@ -1,12 +1,13 @@
 package httpUtil
 
 import (
+	"log"
 	"net/http"
-	"regexp"
 )
 
 // ChainPathTokenHandler is the interface for application to implement for chaining Path Param url feature. Framework will process and pass in the placeholder values inside the pathParam map.
 // bool return true or false to indicate to call the next handler or not
+// new code can implement ChainNextHandler, call PathParam(r, name) and register it with AddChainHandlerPlaceholder instead.
 type ChainPathTokenHandler interface {
 	ServeNextHTTP(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool
 }
@@ -17,12 +18,33 @@ type ChainNextHandler interface {
 	ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool
 }
 
+type chainPathTokenAdapter struct {
+	ChainPathTokenHandler ChainPathTokenHandler
+}
+
+func (a *chainPathTokenAdapter) ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool {
+	return a.ChainPathTokenHandler.ServeNextHTTP(w, r, PathParams(r))
+}
+
+// ChainPathTokenAdapter to turn a ChainPathTokenHandler into a ChainNextHandler. The pathParam map is taken from the request context.
+func ChainPathTokenAdapter(pathTokenHandler ChainPathTokenHandler) ChainNextHandler {
+	return &chainPathTokenAdapter{ChainPathTokenHandler: pathTokenHandler}
+}
+
+func chainPathTokenAdapterList(pathTokenHandler []ChainPathTokenHandler) []ChainNextHandler {
+	handler := make([]ChainNextHandler, len(pathTokenHandler))
+	for index, value := range pathTokenHandler {
+		handler[index] = ChainPathTokenAdapter(value)
+	}
+	return handler
+}
+
 // AddChainHandler to add url mapping to handler. Direct url syntax. handler []ChainNextHandler where first handler will be processed then the next etc until the last handler.
 func AddChainHandler(urlMapping string, handler []ChainNextHandler, httpVerb ...string) {
 	initMapHandler()
 	mutexHttp.Lock()
 	defer mutexHttp.Unlock()
-	addChainHandlerInternal(urlMapping, handler, nil, nil, nil, httpVerb...)
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPath, ChainNextHandler: handler}, httpVerb...)
 }
 
 // AddChainHandlerRegEx to add url mapping to handler. Regular expression url syntax. handler []ChainNextHandler where first handler will be processed then the next etc until the last handler.
@@ -34,45 +56,26 @@ func AddChainHandlerRegEx(urlMapping string, handler []ChainNextHandler, httpVer
 	if re == nil {
 		return
 	}
-	addChainHandlerInternal(urlMapping, handler, nil, nil, re, httpVerb...)
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchRegEx, ChainNextHandler: handler, RegEx: re}, httpVerb...)
 }
 
-// AddChainHandlerPathParam to add url mapping to handler. Placeholder syntax supported are {} and :
-// 	Example {id} or :id
+// AddChainHandlerPathParam to add url mapping to handler. Placeholder syntax supported are {} and : with the same constraint and catch-all syntax as AddHandlerPathParam
+// 	Example {id} or :id or {id:int} or {*path}
 // handler []ChainPathTokenHandler where first handler will be processed then the next etc until the last handler.
 func AddChainHandlerPathParam(urlMapping string, pathTokenHandler []ChainPathTokenHandler, httpVerb ...string) {
+	AddChainHandlerPlaceholder(urlMapping, chainPathTokenAdapterList(pathTokenHandler), httpVerb...)
+}
+
+// AddChainHandlerPlaceholder to add url mapping to handler. Placeholder syntax supported are the same as AddHandlerPathParam
+// handler []ChainNextHandler where first handler will be processed then the next etc until the last handler. each handler call PathParam(r, name) to read the placeholder values.
+func AddChainHandlerPlaceholder(urlMapping string, handler []ChainNextHandler, httpVerb ...string) {
 	initMapHandler()
 	mutexHttp.Lock()
 	defer mutexHttp.Unlock()
-	pathToken := splitBySlashToken(urlMapping)
-	addChainHandlerInternal(urlMapping, nil, pathTokenHandler, pathToken, nil, httpVerb...)
-}
-
-func addChainHandlerInternal(urlMapping string, handler []ChainNextHandler, pathTokenHandler []ChainPathTokenHandler, pathToken []string, re *regexp.Regexp, httpVerb ...string) {
-	if len(httpVerb) == 0 { //default to http.MethodGet
-		if re == nil && pathTokenHandler == nil {
-			mapHandler[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, ChainNextHandler: handler}
-		} else if pathTokenHandler != nil {
-			mapHandlerPathParam[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, ChainPathTokenHandler: pathTokenHandler, PathToken: pathToken}
-		} else if re != nil {
-			mapHandlerRegEx[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, ChainNextHandler: handler, RegEx: re}
-		}
+	pathToken, err := parsePathParamToken(urlMapping)
+	if err != nil {
+		log.Print(err)
 		return
 	}
-
-	var verbs []string
-	for _, verb := range httpVerb {
-		if validHttpVerb[verb] {
-			verbs = append(verbs, verb)
-		}
-	}
-	if len(verbs) != 0 {
-		if re == nil && pathTokenHandler == nil {
-			mapHandler[urlMapping] = &httpVerbHandler{HttpVerb: verbs, ChainNextHandler: handler}
-		} else if pathTokenHandler != nil {
-			mapHandlerPathParam[urlMapping] = &httpVerbHandler{HttpVerb: verbs, ChainPathTokenHandler: pathTokenHandler, PathToken: pathToken}
-		} else if re != nil {
-			mapHandlerRegEx[urlMapping] = &httpVerbHandler{HttpVerb: verbs, ChainNextHandler: handler, RegEx: re}
-		}
-	}
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPathParam, ChainNextHandler: handler, PathToken: pathToken}, httpVerb...)
 }
@@ -0,0 +1,53 @@
+package httpUtil
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeInfoKey struct{}
+
+var routePatternKey = NewStateKey[string]("route")
+
+type routeInfo struct {
+	Pattern   string
+	PathParam map[string]string
+}
+
+func withRouteInfo(r *http.Request, pattern string, pathParam map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, &routeInfo{Pattern: pattern, PathParam: pathParam}))
+}
+
+func getRouteInfo(r *http.Request) *routeInfo {
+	if value, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+		return value
+	}
+	return nil
+}
+
+// PathParam to get the placeholder value matched by a path param url mapping e.g AddHandlerPlaceholder. Return "" if not found.
+// 	Example for url mapping /user/{id} call PathParam(r, "id")
+func PathParam(r *http.Request, name string) string {
+	if info := getRouteInfo(r); info != nil {
+		return info.PathParam[name]
+	}
+	return ""
+}
+
+// PathParams to get all the placeholder values matched by a path param url mapping e.g AddHandlerPlaceholder. Return an empty map if none.
+// the map can be passed to ParamInt, ParamUUID to get typed values.
+func PathParams(r *http.Request) map[string]string {
+	if info := getRouteInfo(r); info != nil && info.PathParam != nil {
+		return info.PathParam
+	}
+	return map[string]string{}
+}
+
+// RoutePattern to get the original url mapping registered for the matched handler e.g /user/{id} which is useful for logging and metrics. Return "" if not matched by any url mapping.
+// a global middleware can call it after the next handler return as the url mapping is matched inside.
+func RoutePattern(r *http.Request) string {
+	if info := getRouteInfo(r); info != nil {
+		return info.Pattern
+	}
+	return routePatternKey.GetOr(r, "")
+}
@@ -0,0 +1,107 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+type pathTokenFunc func(w http.ResponseWriter, r *http.Request, pathParam map[string]string)
+
+func (f pathTokenFunc) ServeHTTP(w http.ResponseWriter, r *http.Request, pathParam map[string]string) {
+	f(w, r, pathParam)
+}
+
+type chainPathTokenFunc func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool
+
+func (f chainPathTokenFunc) ServeNextHTTP(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool {
+	return f(w, r, pathParam)
+}
+
+type chainNextFunc func(w http.ResponseWriter, r *http.Request) bool
+
+func (f chainNextFunc) ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool {
+	return f(w, r)
+}
+
+func TestRouteInfo(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/ctx/user/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, RoutePattern(r)+" id="+PathParam(r, "id")+" missing="+PathParam(r, "missing"))
+		}))
+		AddHandler("/ctx/plain", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "pattern="+RoutePattern(r)+" id="+PathParam(r, "id"))
+		}))
+		AddChainHandlerPlaceholder("/ctx/chain/{id}", []ChainNextHandler{
+			chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				io.WriteString(w, "first="+PathParam(r, "id"))
+				return true
+			}),
+			chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				io.WriteString(w, " second="+PathParam(r, "id"))
+				return true
+			}),
+		})
+	})
+	for target, want := range map[string]string{
+		"/ctx/user/7":  "/ctx/user/{id} id=7 missing=",
+		"/ctx/plain":   "pattern=/ctx/plain id=",
+		"/ctx/chain/8": "first=8 second=8",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", target, w.Body.String(), want)
+		}
+	}
+}
+
+func TestPathTokenAdapter(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/adapter/{id}", PathTokenAdapter(pathTokenFunc(func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) {
+			io.WriteString(w, "id="+pathParam["id"])
+		})))
+		AddChainHandlerPlaceholder("/adapter/chain/{id}", []ChainNextHandler{
+			ChainPathTokenAdapter(chainPathTokenFunc(func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool {
+				io.WriteString(w, "stop="+pathParam["id"])
+				return false
+			})),
+			chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				io.WriteString(w, " not reached")
+				return true
+			}),
+		})
+	})
+	for target, want := range map[string]string{"/adapter/1": "id=1", "/adapter/chain/2": "stop=2"} {
+		if w := serveTest(handler, http.MethodGet, target); w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", target, w.Body.String(), want)
+		}
+	}
+}
+
+func TestAddHandlerPathParamToken(t *testing.T) {
+	echoToken := pathTokenFunc(func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) {
+		io.WriteString(w, "id="+pathParam["id"]+" "+RoutePattern(r))
+	})
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPathParam("/token/{id:int}", echoToken)
+		Group("/group").AddHandlerPathParam("/token/:id", echoToken)
+		AddChainHandlerPathParam("/token/chain/{id}", []ChainPathTokenHandler{
+			chainPathTokenFunc(func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool {
+				io.WriteString(w, "first="+pathParam["id"])
+				return true
+			}),
+			chainPathTokenFunc(func(w http.ResponseWriter, r *http.Request, pathParam map[string]string) bool {
+				io.WriteString(w, " second="+pathParam["id"])
+				return true
+			}),
+		}, http.MethodGet)
+	})
+	for target, want := range map[string]string{
+		"/token/1":       "id=1 /token/{id:int}",
+		"/group/token/2": "id=2 /group/token/:id",
+		"/token/chain/3": "first=3 second=3",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", target, w.Body.String(), want)
+		}
+	}
+}
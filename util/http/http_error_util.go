@@ -1,77 +1,268 @@
 package httpUtil
 
 import (
+	"bytes"
+	"errors"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	logUtil "tiger/util/log"
+	templateUtil "tiger/util/template"
 )
 
 type customHttpError struct {
-	ErrorCode  int
-	Error      string
-	ErrorPage  string
-	RespHeader map[string]string
+	ErrorCode     int
+	Error         string
+	ErrorPage     string
+	ErrorTemplate string
+	RespHeader    map[string]string
+}
+
+// ErrorPageData is the data passed to the template of AddCustomErrorTemplate.
+// 	Example
+// 	<h1>{{.Status}} {{or (.T "error.title") .StatusText}}</h1>
+// 	<p>{{.Message}}</p>
+// 	<small>request {{.RequestId}} {{.Method}} {{.Path}}</small>
+type ErrorPageData struct {
+	Status     int
+	StatusText string
+	Message    string
+	RequestId  string
+	Method     string
+	Path       string
+	//language tags of the Accept-Language header in preference order, used by T
+	Lang []string
 }
 
 var onceCustomHttpError sync.Once
 var mapCustomHttpError map[int]*customHttpError
+var mapHostCustomHttpError map[string]map[int]*customHttpError
 var mutexHttpError sync.RWMutex
+var errorTextFunc func(tag string, key string, param ...interface{}) string
+var errorTextDefaultTag string
 
 // NotFound to show custom not found page if configured else revert to Go default.
+// a client preferring json get an application/problem+json document instead, see WriteProblem
 func NotFound(w http.ResponseWriter, r *http.Request) {
-	initHttpError()
-	mutexHttpError.RLock()
-	defer mutexHttpError.RUnlock()
-	if value, found := mapCustomHttpError[http.StatusNotFound]; found {
-		logUtil.DebugPrint("call error page " + value.ErrorPage)
-		respHeader := value.RespHeader
-		if respHeader != nil {
-			for key, value := range respHeader {
-				w.Header().Set(key, value)
-			}
-		}
-		http.ServeFile(w, r, value.ErrorPage)
+	reportError(w, r, http.StatusNotFound, errors.New("404 page not found"), false, nil)
+	if config := getProblemConfig(r); preferProblem(r, config) {
+		writeProblem(w, r, &Problem{Status: http.StatusNotFound}, config)
+	} else if value := getCustomHttpError(r, http.StatusNotFound); value != nil {
+		serveCustomHttpError(w, r, value, "404 page not found")
 	} else {
 		http.NotFound(w, r)
 	}
 }
 
 // Error to show custom error page if configured else revert to Go default.
+// custom error page added through Host(...).AddCustomErrorPage are used first when the request host match.
+// a client preferring json get an application/problem+json document with error as detail instead, see WriteProblem
+// the error is passed to the ErrorReporter if its code is reported, see AddErrorReporter
 func Error(w http.ResponseWriter, r *http.Request, error string, code int) {
+	reportError(w, r, code, errors.New(error), false, nil)
+	errorInternal(w, r, error, code)
+}
+
+// errorInternal is Error without reporting, for callers which reported the error already.
+func errorInternal(w http.ResponseWriter, r *http.Request, error string, code int) {
+	if config := getProblemConfig(r); preferProblem(r, config) {
+		problem := &Problem{Status: code}
+		if error != http.StatusText(code) {
+			problem.Detail = error
+		}
+		writeProblem(w, r, problem, config)
+	} else if value := getCustomHttpError(r, code); value != nil {
+		serveCustomHttpError(w, r, value, error)
+	} else {
+		http.Error(w, error, code)
+	}
+}
+
+func getCustomHttpError(r *http.Request, code int) *customHttpError {
 	initHttpError()
+	matched := matchHost(r.Host)
 	mutexHttpError.RLock()
 	defer mutexHttpError.RUnlock()
-	if value, found := mapCustomHttpError[code]; found {
+	for _, hm := range matched {
+		if value, found := mapHostCustomHttpError[hm.Pattern][code]; found {
+			return value
+		}
+	}
+	return mapCustomHttpError[code]
+}
+
+// serveCustomHttpError to write the error page with its status code. the template is used first, then the static file, then the Go default.
+func serveCustomHttpError(w http.ResponseWriter, r *http.Request, value *customHttpError, message string) {
+	var b []byte
+	var contentType string
+	if value.ErrorTemplate != "" {
+		logUtil.DebugPrint("call error template " + value.ErrorTemplate)
+		var err error
+		if b, err = renderErrorTemplate(w, r, value, message); err != nil {
+			log.Print("error render error template " + value.ErrorTemplate + ": " + err.Error())
+		} else {
+			contentType = "text/html; charset=utf-8"
+		}
+	}
+	if b == nil && value.ErrorPage != "" {
 		logUtil.DebugPrint("call error page " + value.ErrorPage)
-		respHeader := value.RespHeader
-		if respHeader != nil {
-			for key, value := range respHeader {
-				w.Header().Set(key, value)
+		var err error
+		if b, err = os.ReadFile(value.ErrorPage); err != nil {
+			log.Print("error read error page " + value.ErrorPage + ": " + err.Error())
+			b = nil
+		} else if contentType = mime.TypeByExtension(filepath.Ext(value.ErrorPage)); contentType == "" {
+			contentType = http.DetectContentType(b)
+		}
+	}
+	if b == nil {
+		http.Error(w, message, value.ErrorCode)
+		return
+	}
+	for key, header := range value.RespHeader {
+		w.Header().Set(key, header)
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(value.ErrorCode)
+	w.Write(b)
+}
+
+func renderErrorTemplate(w http.ResponseWriter, r *http.Request, value *customHttpError, message string) ([]byte, error) {
+	tpl, err := templateUtil.GetTemplate(value.ErrorTemplate)
+	if err != nil { //template folder not loaded or outside of it
+		if tpl, err = templateUtil.AddTemplate(value.ErrorTemplate); err != nil {
+			return nil, err
+		}
+	}
+	data := &ErrorPageData{Status: value.ErrorCode, StatusText: value.Error, Message: message, Method: r.Method, Path: r.URL.Path, Lang: acceptLanguage(r), RequestId: requestId(w, r)}
+	var buffer bytes.Buffer
+	if err := tpl.Execute(&buffer, data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// T to get the localized text of key in the first language of Lang having it, else in the default language of SetErrorText. return empty string if none.
+func (d *ErrorPageData) T(key string, param ...interface{}) string {
+	mutexHttpError.RLock()
+	fn, defaultTag := errorTextFunc, errorTextDefaultTag
+	mutexHttpError.RUnlock()
+	if fn == nil {
+		return ""
+	}
+	for _, tag := range append(d.Lang, defaultTag) {
+		if text := fn(tag, key, param...); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// SetErrorText to set the func giving the localized text called by {{.T "key"}} in error templates, defaultTag is tried after the Accept-Language ones.
+// 	Example SetErrorText(i18nUtil.GetMsg, "en")
+func SetErrorText(fn func(tag string, key string, param ...interface{}) string, defaultTag string) {
+	mutexHttpError.Lock()
+	defer mutexHttpError.Unlock()
+	errorTextFunc = fn
+	errorTextDefaultTag = defaultTag
+}
+
+// acceptLanguage to get the language tags of the Accept-Language header by preference, en-US is followed by en.
+func acceptLanguage(r *http.Request) []string {
+	type language struct {
+		Tag     string
+		Quality float64
+	}
+	var list []language
+	for _, value := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		part := strings.Split(value, ";")
+		item := language{Tag: strings.TrimSpace(part[0]), Quality: 1}
+		for _, param := range part[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if f, err := strconv.ParseFloat(q[2:], 64); err == nil {
+					item.Quality = f
+				}
+			}
+		}
+		if item.Tag != "" && item.Tag != "*" && item.Quality > 0 {
+			list = append(list, item)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Quality > list[j].Quality
+	})
+	var tag []string
+	found := make(map[string]bool)
+	for _, value := range list {
+		for _, name := range []string{value.Tag, strings.Split(value.Tag, "-")[0]} {
+			if !found[name] {
+				found[name] = true
+				tag = append(tag, name)
 			}
 		}
-		http.ServeFile(w, r, value.ErrorPage)
-	} else {
-		http.Error(w, error, code)
 	}
+	return tag
 }
 
 func initHttpError() {
 	onceCustomHttpError.Do(func() { //singleton
 		mapCustomHttpError = make(map[int]*customHttpError)
+		mapHostCustomHttpError = make(map[string]map[int]*customHttpError)
 	})
 }
 
 // AddCustomErrorPage to show any application defined custom error page if configured else revert to Go default.
+// the static file is written with the errorCode as status code. it is also the fallback of AddCustomErrorTemplate for the same errorCode.
 func AddCustomErrorPage(errorCode int, errorPage string, respHeader map[string]string) {
+	addCustomErrorPageInternal("", errorCode, errorPage, "", respHeader)
+}
+
+// AddCustomErrorTemplate to show an error page rendered from the template with ErrorPageData and written with the errorCode as status code.
+// if rendering fail it is logged and the static page of AddCustomErrorPage for the same errorCode is served, else the Go default.
+// the template is taken from templateUtil, a template outside of the TemplateConfig path is parsed on first use.
+// 	Example
+// 	AddCustomErrorTemplate(http.StatusNotFound, "templates/errors/error.html", nil)
+// 	AddCustomErrorPage(http.StatusNotFound, "templates/errors/404Error.html", nil)
+func AddCustomErrorTemplate(errorCode int, template string, respHeader map[string]string) {
+	addCustomErrorPageInternal("", errorCode, "", template, respHeader)
+}
+
+// addCustomErrorPageInternal to set the static page or the template of errorCode, keeping the other one already added.
+func addCustomErrorPageInternal(host string, errorCode int, errorPage string, template string, respHeader map[string]string) {
 	initHttpError()
 	mutexHttpError.Lock()
 	defer mutexHttpError.Unlock()
-	if _, err := os.Stat(errorPage); os.IsNotExist(err) {
-		log.Print("error find error page " + errorPage)
+	file := errorPage + template
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		log.Print("error find error page " + file)
 		return
 	}
-	logUtil.DebugPrint("process " + errorPage)
-	mapCustomHttpError[errorCode] = &customHttpError{ErrorCode: errorCode, Error: http.StatusText(errorCode), ErrorPage: errorPage, RespHeader: respHeader}
+	logUtil.DebugPrint("process " + file)
+	mapCode := mapCustomHttpError
+	if host != "" {
+		if _, found := mapHostCustomHttpError[host]; !found {
+			mapHostCustomHttpError[host] = make(map[int]*customHttpError)
+		}
+		mapCode = mapHostCustomHttpError[host]
+	}
+	value := &customHttpError{ErrorCode: errorCode, Error: http.StatusText(errorCode), ErrorPage: errorPage, ErrorTemplate: template, RespHeader: respHeader}
+	if existing, found := mapCode[errorCode]; found { //copy as the existing one may be served right now
+		if errorPage == "" {
+			value.ErrorPage = existing.ErrorPage
+		}
+		if template == "" {
+			value.ErrorTemplate = existing.ErrorTemplate
+		}
+		if respHeader == nil {
+			value.RespHeader = existing.RespHeader
+		}
+	}
+	mapCode[errorCode] = value
 }
@@ -0,0 +1,87 @@
+package httpUtil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFile to write content to name in dir and get its path.
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCustomErrorTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tpl := writeTestFile(t, dir, "error.html", `{{.Status}} {{or (.T "error.title") .StatusText}} {{.Message}} {{.RequestId}} {{.Method}} {{.Path}}`)
+	broken := writeTestFile(t, dir, "broken.html", `{{.Missing}}`)
+	page := writeTestFile(t, dir, "503.html", `<p>static 503</p>`)
+	hostTpl := writeTestFile(t, dir, "host.html", `host {{.Status}}`)
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddCustomErrorTemplate(http.StatusNotFound, tpl, map[string]string{"Cache-Control": "no-store"})
+		AddCustomErrorTemplate(http.StatusInternalServerError, tpl, nil)
+		AddCustomErrorPage(http.StatusServiceUnavailable, page, nil)
+		AddCustomErrorTemplate(http.StatusServiceUnavailable, broken, nil)
+		Host("api.example.com").AddCustomErrorTemplate(http.StatusNotFound, hostTpl, nil)
+		AddHandler("/error/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("error page")
+		}))
+		AddHandler("/error/busy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "busy", http.StatusServiceUnavailable)
+		}))
+	})
+	SetErrorText(func(tag string, key string, param ...interface{}) string {
+		if tag == "fr" && key == "error.title" {
+			return "introuvable"
+		}
+		return ""
+	}, "en")
+
+	r := httptest.NewRequest(http.MethodGet, "/error/missing", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	r.Header.Set("Accept-Language", "de;q=0.5, fr-CH")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound || w.Body.String() != "404 introuvable 404 page not found req-1 GET /error/missing" {
+		t.Errorf("GET /error/missing = %d %q, want the 404 template", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/html; charset=utf-8" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("GET /error/missing header %v, want html and the response header of the page", w.Header())
+	}
+	if w := serveTest(handler, http.MethodGet, "/error/panic"); w.Code != http.StatusInternalServerError || w.Body.String() != "500 Internal Server Error Internal Server Error  GET /error/panic" {
+		t.Errorf("GET /error/panic = %d %q, want the 500 template", w.Code, w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/error/busy"); w.Code != http.StatusServiceUnavailable || w.Body.String() != "<p>static 503</p>" {
+		t.Errorf("GET /error/busy = %d %q, want the static page when the template fail", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/error/missing", nil)
+	r.Host = "api.example.com"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound || w.Body.String() != "host 404" {
+		t.Errorf("GET api.example.com/error/missing = %d %q, want the host template", w.Code, w.Body.String())
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "en;q=0.3, *, fr-CH, de;q=0, pt-BR;q=0.8")
+	want := []string{"fr-CH", "fr", "pt-BR", "pt", "en"}
+	if tag := acceptLanguage(r); len(tag) != len(want) {
+		t.Errorf("acceptLanguage = %v, want %v", tag, want)
+	} else {
+		for i := range want {
+			if tag[i] != want[i] {
+				t.Errorf("acceptLanguage = %v, want %v", tag, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package httpUtil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	logUtil "tiger/util/log"
+	"time"
+)
+
+// FanOut is a chain step running its tasks concurrently with a shared deadline before continuing the chain. the result of each task is stored in the RequestState under its StateKey.
+// fail fast (default) stop at the first task error or the deadline and respond by WriteError, 504 for the deadline. the tasks still running get their context cancelled.
+// with TolerateFailure every task error is kept in FanOutErrors(r) and the chain continue with the results of the successful tasks.
+// every task must have its own StateKey, a FanOut with two tasks of the same StateKey respond 500 without running them.
+// when the client goes away the chain stops quietly without writing a response.
+// 	Example
+// 	var ProfileKey = NewStateKey[[]byte]("profile")
+// 	var OrderKey = NewStateKey[[]byte]("order")
+// 	fanOut := &FanOut{Timeout: 2 * time.Second, Task: []FanOutTask{
+// 		NewFanOutTask(ProfileKey, func(ctx context.Context, r *http.Request) ([]byte, error) {
+// 			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://profile/"+PathParam(r, "id"), nil)
+// 			return clientUtil.HttpDo(req, &clientUtil.RequestInfo{TimeoutSec: 2})
+// 		}),
+// 		NewFanOutTask(OrderKey, loadOrder),
+// 	}}
+// 	AddChainHandlerPlaceholder("/aggregate/{id}", []ChainNextHandler{&logic3.AuthHandler{}, fanOut, &logic3.AggregateHandler{}}, http.MethodGet)
+type FanOut struct {
+	Task []FanOutTask
+	//shared deadline of all the tasks. 0 is only bounded by the request context
+	Timeout time.Duration
+	//false to fail fast on the first error, true to continue the chain with partial results
+	TolerateFailure bool
+}
+
+// FanOutTask is one sub handler of a FanOut. create it by NewFanOutTask.
+type FanOutTask struct {
+	Name string
+	//the StateKey as stored in the RequestState, two keys of the same name but another type are different tasks
+	key interface{}
+	run func(ctx context.Context, r *http.Request) (interface{}, error)
+	set func(r *http.Request, value interface{})
+}
+
+type fanOutResult struct {
+	Index int
+	Value interface{}
+	Err   error
+}
+
+var fanOutErrorKey = NewStateKey[map[string]error]("fanout-error")
+
+// NewFanOutTask to get a FanOutTask storing the value returned by run under key. run must stop when ctx is done, the request passed in carry ctx.
+func NewFanOutTask[T any](key StateKey[T], run func(ctx context.Context, r *http.Request) (T, error)) FanOutTask {
+	return FanOutTask{
+		Name: key.Name(),
+		key:  key,
+		run: func(ctx context.Context, r *http.Request) (interface{}, error) {
+			return run(ctx, r)
+		},
+		set: func(r *http.Request, value interface{}) {
+			key.Set(r, value.(T))
+		},
+	}
+}
+
+// FanOutErrors to get the task name to error of the FanOut steps with TolerateFailure of the request. tasks passing the deadline have context.DeadlineExceeded.
+func FanOutErrors(r *http.Request) map[string]error {
+	return fanOutErrorKey.GetOr(r, map[string]error{})
+}
+
+// ServeNextHTTP is implementation method for the ChainNextHandler interface.
+func (f *FanOut) ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool {
+	return ChainErrorAdapter(f).ServeNextHTTP(w, r)
+}
+
+// ServeChainHTTP is implementation method for the ChainErrorHandler interface.
+// only this func store the results so a task ignoring its context cannot change the RequestState after the step is done.
+func (f *FanOut) ServeChainHTTP(w http.ResponseWriter, r *http.Request) error {
+	if err := f.checkName(); err != nil {
+		return err
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if f.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), f.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(r.Context())
+	}
+	defer cancel()
+	taskRequest := r.WithContext(ctx)
+	result := make(chan fanOutResult, len(f.Task)) //buffered so late task never block
+	for index, task := range f.Task {
+		go func(index int, task FanOutTask) {
+			defer func() {
+				if value := recover(); value != nil {
+					logUtil.ErrorPrintf("panic in fan out task %s %s %s: %v\n%s", task.Name, r.Method, r.URL.Path, value, debug.Stack())
+					result <- fanOutResult{Index: index, Err: fmt.Errorf("panic: %v", value)}
+				}
+			}()
+			value, err := task.run(ctx, taskRequest)
+			result <- fanOutResult{Index: index, Value: value, Err: err}
+		}(index, task)
+	}
+
+	failed := make(map[string]error)
+	done := make([]bool, len(f.Task))
+	for remain := len(f.Task); remain > 0; remain-- {
+		select {
+		case value := <-result:
+			done[value.Index] = true
+			name := f.Task[value.Index].Name
+			if value.Err == nil {
+				f.Task[value.Index].set(r, value.Value)
+				continue
+			}
+			if !f.TolerateFailure {
+				return fanOutError(r, name, value.Err)
+			}
+			failed[name] = value.Err
+		case <-ctx.Done():
+			for index, task := range f.Task {
+				if !done[index] {
+					if !f.TolerateFailure || r.Context().Err() != nil { //no one to continue the chain for once the client is gone
+						return fanOutError(r, task.Name, ctx.Err())
+					}
+					failed[task.Name] = ctx.Err()
+				}
+			}
+			remain = 0
+		}
+	}
+	if len(failed) > 0 {
+		merged := FanOutErrors(r)
+		for key, value := range failed {
+			merged[key] = value
+		}
+		fanOutErrorKey.Set(r, merged)
+	}
+	return nil
+}
+
+// checkName to reject tasks of the same StateKey as their result would overwrite each other.
+func (f *FanOut) checkName() error {
+	key := make(map[interface{}]bool, len(f.Task))
+	for _, task := range f.Task {
+		if key[task.key] {
+			return errors.New("fan out task " + task.Name + " added twice, each task need its own StateKey")
+		}
+		key[task.key] = true
+	}
+	return nil
+}
+
+// fanOutError to get the error of the failed task, ErrStopChain when the request is cancelled by the client as nothing can be written anymore.
+func fanOutError(r *http.Request, name string, err error) error {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil { //client gone
+		return fmt.Errorf("fan out task %s: %w", name, ErrStopChain)
+	}
+	err = fmt.Errorf("fan out task %s: %w", name, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewHttpError(http.StatusGatewayTimeout, "", err)
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package httpUtil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fanOutAKey = NewStateKey[string]("fanout-a")
+var fanOutBKey = NewStateKey[string]("fanout-b")
+
+func fanOutValue(value string, err error, delay time.Duration) func(ctx context.Context, r *http.Request) (string, error) {
+	return func(ctx context.Context, r *http.Request) (string, error) {
+		select {
+		case <-time.After(delay):
+			return value, err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// fanOutResultHandler write the results and the sorted task errors of the request.
+var fanOutResultHandler = chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+	a, _ := fanOutAKey.Get(r)
+	b, _ := fanOutBKey.Get(r)
+	var failed []string
+	for name := range FanOutErrors(r) {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	io.WriteString(w, "a="+a+" b="+b+" failed="+strings.Join(failed, ","))
+	return true
+})
+
+func TestFanOut(t *testing.T) {
+	for name, test := range map[string]struct {
+		FanOut *FanOut
+		Status int
+		Body   string
+	}{
+		"ok": {&FanOut{Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, fanOutValue("1", nil, 0)),
+			NewFanOutTask(fanOutBKey, fanOutValue("2", nil, 10*time.Millisecond)),
+		}}, http.StatusOK, "a=1 b=2 failed="},
+		"fail fast": {&FanOut{Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, fanOutValue("1", nil, 0)),
+			NewFanOutTask(fanOutBKey, fanOutValue("", errors.New("down"), 0)),
+		}}, http.StatusInternalServerError, ""},
+		"deadline": {&FanOut{Timeout: 20 * time.Millisecond, Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, fanOutValue("1", nil, time.Second)),
+		}}, http.StatusGatewayTimeout, ""},
+		"tolerate": {&FanOut{Timeout: 20 * time.Millisecond, TolerateFailure: true, Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, fanOutValue("1", nil, time.Second)),
+			NewFanOutTask(fanOutBKey, fanOutValue("2", nil, 0)),
+		}}, http.StatusOK, "a= b=2 failed=fanout-a"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := newTestHandler(t, newTestConfig(), func() {
+				AddChainHandler("/fanout", []ChainNextHandler{test.FanOut, fanOutResultHandler})
+			})
+			w := serveTest(handler, http.MethodGet, "/fanout")
+			if w.Code != test.Status || (test.Body != "" && w.Body.String() != test.Body) {
+				t.Errorf("GET /fanout = %d %q, want %d %q", w.Code, w.Body.String(), test.Status, test.Body)
+			}
+		})
+	}
+}
+
+func TestFanOutDuplicateName(t *testing.T) {
+	var run int32
+	task := func(ctx context.Context, r *http.Request) (string, error) {
+		atomic.AddInt32(&run, 1)
+		return "", errors.New("down")
+	}
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddChainHandler("/fanout", []ChainNextHandler{&FanOut{TolerateFailure: true, Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, task),
+			NewFanOutTask(fanOutAKey, task),
+		}}, fanOutResultHandler})
+	})
+	if w := serveTest(handler, http.MethodGet, "/fanout"); w.Code != http.StatusInternalServerError {
+		t.Errorf("GET /fanout with duplicate task = %d, want 500", w.Code)
+	}
+	if atomic.LoadInt32(&run) != 0 {
+		t.Error("tasks of a FanOut with duplicate name were run")
+	}
+}
+
+func TestFanOutSameNameOtherType(t *testing.T) {
+	countKey := NewStateKey[int]("fanout-a")
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddChainHandler("/fanout", []ChainNextHandler{&FanOut{Task: []FanOutTask{
+			NewFanOutTask(fanOutAKey, fanOutValue("1", nil, 0)),
+			NewFanOutTask(countKey, func(ctx context.Context, r *http.Request) (int, error) {
+				return 2, nil
+			}),
+		}}, chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+			a, _ := fanOutAKey.Get(r)
+			count, _ := countKey.Get(r)
+			io.WriteString(w, a+" "+strconv.Itoa(count))
+			return true
+		})})
+	})
+	if w := serveTest(handler, http.MethodGet, "/fanout"); w.Code != http.StatusOK || w.Body.String() != "1 2" {
+		t.Errorf("GET /fanout = %d %q, want 200 %q", w.Code, w.Body.String(), "1 2")
+	}
+}
+
+func TestFanOutClientGone(t *testing.T) {
+	for _, tolerate := range []bool{false, true} {
+		var next int32
+		handler := newTestHandler(t, newTestConfig(), func() {
+			AddChainHandler("/fanout", []ChainNextHandler{&FanOut{TolerateFailure: tolerate, Task: []FanOutTask{
+				NewFanOutTask(fanOutAKey, fanOutValue("1", nil, time.Second)),
+			}}, chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				atomic.AddInt32(&next, 1)
+				return true
+			})})
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fanout", nil).WithContext(ctx))
+		if w.Code != http.StatusOK || w.Body.Len() != 0 || atomic.LoadInt32(&next) != 0 {
+			t.Errorf("tolerate %v: client gone = %d %q next %d, want no response and the chain stopped", tolerate, w.Code, w.Body.String(), next)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package httpUtil
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RouteGroup is a set of url mapping sharing the same host pattern and/or url prefix.
+// url mapping added through a RouteGroup with a host pattern are only matched when the request host match, else the default host url mapping are used as fallback.
+type RouteGroup struct {
+	host       string
+	prefix     string
+	err        error
+	middleware []Middleware
+}
+
+// Host to get a RouteGroup bound to the host pattern. Port in the request host is ignored.
+// 	Example exact api.example.com
+// 	Example wildcard *.example.com match any sub-domain of example.com
+// 	Example placeholder {tenant}.example.com where tenant is read by PathParam(r, "tenant") like a path param
+// when more than one host pattern match, exact host is tried first then placeholder host then wildcard host.
+func Host(hostPattern string) *RouteGroup {
+	host, err := addHostPattern(hostPattern)
+	if err != nil {
+		log.Print(err)
+	}
+	return &RouteGroup{host: host, err: err}
+}
+
+// Group to get a RouteGroup on the default host where all url mapping are prefixed with prefix.
+// 	Example Group("/api/v1").AddHandler("/user", ...) is the same as AddHandler("/api/v1/user", ...)
+func Group(prefix string) *RouteGroup {
+	return &RouteGroup{prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// Group to get a sub RouteGroup with the same host pattern where all url mapping are prefixed with prefix after the parent prefix.
+func (g *RouteGroup) Group(prefix string) *RouteGroup {
+	return &RouteGroup{host: g.host, prefix: g.prefix + strings.TrimSuffix(prefix, "/"), err: g.err, middleware: append([]Middleware(nil), g.middleware...)}
+}
+
+// Use to wrap every url mapping added through the RouteGroup after this call with the middleware. the first middleware is the outermost and run first.
+// sub RouteGroup created after this call inherit the middleware.
+// 	Example
+// 	api := Group("/api").Use(Timing, ChainNextAdapter(rateLimiter.NewTokenBucketHandler(60, 30, 30)))
+// 	api.AddHandler("/user", &UserHandler{}, http.MethodGet)
+func (g *RouteGroup) Use(middleware ...Middleware) *RouteGroup {
+	g.middleware = append(g.middleware, middleware...)
+	return g
+}
+
+func (g *RouteGroup) urlMapping(urlMapping string) string {
+	return g.prefix + urlMapping
+}
+
+func (g *RouteGroup) urlMappingRegEx(urlMapping string) string {
+	if g.prefix == "" {
+		return urlMapping
+	}
+	return "^" + regexp.QuoteMeta(g.prefix) + strings.TrimPrefix(urlMapping, "^")
+}
+
+func (g *RouteGroup) add(handler *httpVerbHandler, httpVerb ...string) {
+	if g.err != nil {
+		log.Print("skip url mapping " + handler.UrlMapping + " of invalid host pattern")
+		return
+	}
+	handler.Middleware = append([]Middleware(nil), g.middleware...)
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	addHostHandlerInternal(g.host, handler, httpVerb...)
+}
+
+// AddHandler is like the package AddHandler but bound to the RouteGroup.
+func (g *RouteGroup) AddHandler(urlMapping string, handler http.Handler, httpVerb ...string) {
+	g.add(&httpVerbHandler{UrlMapping: g.urlMapping(urlMapping), MatchKind: matchPath, NextHandler: handler}, httpVerb...)
+}
+
+// AddHandlerRegEx is like the package AddHandlerRegEx but bound to the RouteGroup. the prefix is matched from the start of the url.
+func (g *RouteGroup) AddHandlerRegEx(urlMapping string, handler http.Handler, httpVerb ...string) {
+	urlMapping = g.urlMappingRegEx(urlMapping)
+	re := getHandlerRe(urlMapping)
+	if re == nil {
+		return
+	}
+	g.add(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchRegEx, NextHandler: handler, RegEx: re}, httpVerb...)
+}
+
+// AddHandlerPathParam is like the package AddHandlerPathParam but bound to the RouteGroup.
+func (g *RouteGroup) AddHandlerPathParam(urlMapping string, pathTokenHandler PathTokenHandler, httpVerb ...string) {
+	g.AddHandlerPlaceholder(urlMapping, PathTokenAdapter(pathTokenHandler), httpVerb...)
+}
+
+// AddHandlerPlaceholder is like the package AddHandlerPlaceholder but bound to the RouteGroup.
+func (g *RouteGroup) AddHandlerPlaceholder(urlMapping string, handler http.Handler, httpVerb ...string) {
+	initMapHandler()
+	urlMapping = g.urlMapping(urlMapping)
+	pathToken, err := parsePathParamToken(urlMapping)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	g.add(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPathParam, NextHandler: handler, PathToken: pathToken}, httpVerb...)
+}
+
+// AddChainHandler is like the package AddChainHandler but bound to the RouteGroup.
+func (g *RouteGroup) AddChainHandler(urlMapping string, handler []ChainNextHandler, httpVerb ...string) {
+	g.add(&httpVerbHandler{UrlMapping: g.urlMapping(urlMapping), MatchKind: matchPath, ChainNextHandler: handler}, httpVerb...)
+}
+
+// AddChainHandlerRegEx is like the package AddChainHandlerRegEx but bound to the RouteGroup. the prefix is matched from the start of the url.
+func (g *RouteGroup) AddChainHandlerRegEx(urlMapping string, handler []ChainNextHandler, httpVerb ...string) {
+	urlMapping = g.urlMappingRegEx(urlMapping)
+	re := getHandlerRe(urlMapping)
+	if re == nil {
+		return
+	}
+	g.add(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchRegEx, ChainNextHandler: handler, RegEx: re}, httpVerb...)
+}
+
+// AddChainHandlerPathParam is like the package AddChainHandlerPathParam but bound to the RouteGroup.
+func (g *RouteGroup) AddChainHandlerPathParam(urlMapping string, pathTokenHandler []ChainPathTokenHandler, httpVerb ...string) {
+	g.AddChainHandlerPlaceholder(urlMapping, chainPathTokenAdapterList(pathTokenHandler), httpVerb...)
+}
+
+// AddChainHandlerPlaceholder is like the package AddChainHandlerPlaceholder but bound to the RouteGroup.
+func (g *RouteGroup) AddChainHandlerPlaceholder(urlMapping string, handler []ChainNextHandler, httpVerb ...string) {
+	initMapHandler()
+	urlMapping = g.urlMapping(urlMapping)
+	pathToken, err := parsePathParamToken(urlMapping)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	g.add(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPathParam, ChainNextHandler: handler, PathToken: pathToken}, httpVerb...)
+}
+
+// AddCustomErrorPage is like the package AddCustomErrorPage but only used for request matching the RouteGroup host pattern. the prefix is not used.
+func (g *RouteGroup) AddCustomErrorPage(errorCode int, errorPage string, respHeader map[string]string) {
+	if g.err != nil {
+		return
+	}
+	addCustomErrorPageInternal(g.host, errorCode, errorPage, "", respHeader)
+}
+
+// AddCustomErrorTemplate is like the package AddCustomErrorTemplate but only used for request matching the RouteGroup host pattern. the prefix is not used.
+func (g *RouteGroup) AddCustomErrorTemplate(errorCode int, template string, respHeader map[string]string) {
+	if g.err != nil {
+		return
+	}
+	addCustomErrorPageInternal(g.host, errorCode, "", template, respHeader)
+}
+
+// AddRewriteUrl is like the package AddRewriteUrl but only used for request matching the RouteGroup host pattern. the prefix is not used.
+func (g *RouteGroup) AddRewriteUrl(sourceUrl string, targetUrl string) {
+	if g.err != nil {
+		return
+	}
+	addRewriteUrlInternal(g.host, sourceUrl, targetUrl)
+}
+
+// AddRewriteRule is like the package AddRewriteRule but only used for request matching the RouteGroup host pattern. the prefix is not used.
+func (g *RouteGroup) AddRewriteRule(rule RewriteRule) {
+	if g.err != nil {
+		return
+	}
+	addRewriteRuleInternal(g.host, rule, false)
+}
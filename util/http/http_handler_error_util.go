@@ -0,0 +1,175 @@
+package httpUtil
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ErrStopChain is returned by a ChainErrorHandler to stop the chain without an error e.g the response has been written already.
+var ErrStopChain = errors.New("stop chain")
+
+// HttpError is an error carrying the http status code to respond with. Message, Field, Type and Extension are shown to the client, the wrapped Err is only logged.
+type HttpError struct {
+	Status  int
+	Message string
+	//field name to what is wrong with it e.g {"email": "is required"} for BadRequestError, written as the invalid-params of the Problem
+	Field map[string]string
+	Err   error
+	//problem Type and Extension members of the Problem, see WriteProblem
+	Type      string
+	Extension map[string]interface{}
+}
+
+// ErrorHandler is the interface for application to implement a handler returning error instead of writing the error response itself. see WriteError
+type ErrorHandler interface {
+	ServeHTTPError(w http.ResponseWriter, r *http.Request) error
+}
+
+// ErrorHandlerFunc is a func returning error that can be passed to any Add* func as it implement http.Handler.
+// 	Example
+// 	AddHandlerPlaceholder("/user/{id:int}", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+// 		user, err := loadUser(db, PathParam(r, "id"))
+// 		if err == sql.ErrNoRows {
+// 			return NotFoundError("user not found")
+// 		}
+// 		if err != nil {
+// 			return err
+// 		}
+// 		return json.NewEncoder(w).Encode(user)
+// 	}), http.MethodGet)
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ChainErrorHandler is the interface for application to implement for chaining url feature returning error. nil call the next handler, ErrStopChain stop the chain quietly, any other error stop the chain and is written by WriteError.
+type ChainErrorHandler interface {
+	ServeChainHTTP(w http.ResponseWriter, r *http.Request) error
+}
+
+type errorAdapter struct {
+	ErrorHandler ErrorHandler
+}
+
+type chainErrorAdapter struct {
+	ChainErrorHandler ChainErrorHandler
+}
+
+// NewHttpError to get an error responded with the status code and message. err is the cause which is logged but not shown, can be nil.
+func NewHttpError(status int, message string, err error) *HttpError {
+	return &HttpError{Status: status, Message: message, Err: err}
+}
+
+// NotFoundError to get an error responded with 404.
+func NotFoundError(message string) *HttpError {
+	return &HttpError{Status: http.StatusNotFound, Message: message}
+}
+
+// BadRequestError to get an error responded with 400 and the field details e.g {"email": "is required"}. field can be nil.
+func BadRequestError(message string, field map[string]string) *HttpError {
+	return &HttpError{Status: http.StatusBadRequest, Message: message, Field: field}
+}
+
+// ConflictError to get an error responded with 409.
+func ConflictError(message string) *HttpError {
+	return &HttpError{Status: http.StatusConflict, Message: message}
+}
+
+// Error is implementation method for the error interface.
+func (e *HttpError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.Status)
+	}
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+// Unwrap to get the cause for errors.Is and errors.As.
+func (e *HttpError) Unwrap() error {
+	return e.Err
+}
+
+// ServeHTTP is implementation method for the http.Handler interface.
+func (f ErrorHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ErrorAdapter(errorHandlerFunc(f)).ServeHTTP(w, r)
+}
+
+type errorHandlerFunc ErrorHandlerFunc
+
+func (f errorHandlerFunc) ServeHTTPError(w http.ResponseWriter, r *http.Request) error {
+	return f(w, r)
+}
+
+// ErrorAdapter to turn an ErrorHandler into a http.Handler. the returned error is written by WriteError.
+func ErrorAdapter(handler ErrorHandler) http.Handler {
+	return &errorAdapter{ErrorHandler: handler}
+}
+
+func (a *errorAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := WrapResponseWriter(w)
+	if err := a.ErrorHandler.ServeHTTPError(rw, r); err != nil {
+		writeErrorInternal(rw, r, err)
+	}
+}
+
+// ChainErrorAdapter to turn a ChainErrorHandler into a ChainNextHandler for AddChainHandler, AddChainHandlerRegEx, AddChainHandlerPlaceholder.
+func ChainErrorAdapter(handler ChainErrorHandler) ChainNextHandler {
+	return &chainErrorAdapter{ChainErrorHandler: handler}
+}
+
+func (a *chainErrorAdapter) ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool {
+	rw := WrapResponseWriter(w)
+	err := a.ChainErrorHandler.ServeChainHTTP(rw, r)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, ErrStopChain) { //also wrapped e.g fmt.Errorf("%w", ErrStopChain)
+		writeErrorInternal(rw, r, err)
+	}
+	return false
+}
+
+// WriteError to write the error response.
+// an *HttpError anywhere in the err chain (errors.As) give the status code, message, field details and problem type. any other error is logged and responded as 500 without its text.
+// the error is passed to the ErrorReporter if its status is reported, see AddErrorReporter
+// the response is an application/problem+json Problem when the client prefer json (see WriteProblem), else it is served by Error so the custom error page registry is used.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	writeErrorInternal(WrapResponseWriter(w), r, err)
+}
+
+func writeErrorInternal(w *ResponseWriter, r *http.Request, err error) {
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		httpErr = &HttpError{Status: http.StatusInternalServerError, Err: err}
+	}
+	status := httpErr.Status
+	if status < 400 || status > 599 {
+		status = http.StatusInternalServerError
+	}
+	if status >= http.StatusInternalServerError {
+		log.Printf("error %s %s: %v", r.Method, r.URL.Path, err)
+	}
+	reportError(w, r, status, err, false, nil)
+	if w.WroteHeader() {
+		log.Printf("error %s %s after response written: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	message := httpErr.Message
+	if message == "" { //unknown error text is never shown
+		message = http.StatusText(status)
+	}
+	config := getProblemConfig(r)
+	if !preferProblem(r, config) {
+		errorInternal(w, r, message, status)
+		return
+	}
+	problem := &Problem{Type: httpErr.Type, Status: status, Extension: httpErr.Extension}
+	if message != http.StatusText(status) {
+		problem.Detail = message
+	}
+	for name, reason := range httpErr.Field {
+		problem.AddInvalidParam(name, reason)
+	}
+	writeProblem(w, r, problem, config)
+}
@@ -0,0 +1,39 @@
+package httpUtil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type chainErrorFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f chainErrorFunc) ServeChainHTTP(w http.ResponseWriter, r *http.Request) error {
+	return f(w, r)
+}
+
+func TestChainErrorAdapterStopChain(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	for name, test := range map[string]struct {
+		Err    error
+		Next   bool
+		Status int
+	}{
+		"nil":     {nil, true, http.StatusOK},
+		"stop":    {ErrStopChain, false, http.StatusOK},
+		"wrapped": {fmt.Errorf("auth: %w", ErrStopChain), false, http.StatusOK},
+		"error":   {errors.New("db down"), false, http.StatusInternalServerError},
+		"http":    {NotFoundError("no user"), false, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		next := ChainErrorAdapter(chainErrorFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return test.Err
+		})).ServeNextHTTP(w, httptest.NewRequest(http.MethodGet, "/chain", nil))
+		if next != test.Next || w.Code != test.Status {
+			t.Errorf("%s: next %v status %d, want %v %d", name, next, w.Code, test.Next, test.Status)
+		}
+	}
+}
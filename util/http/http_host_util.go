@@ -0,0 +1,134 @@
+package httpUtil
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type hostPattern struct {
+	Pattern    string
+	Token      []*pathParamToken
+	Wildcard   bool
+	ParamCount int
+}
+
+type hostMatch struct {
+	Pattern   string
+	HostParam map[string]string
+}
+
+var onceHost sync.Once
+var mutexHost sync.RWMutex
+var mapHostPattern map[string]*hostPattern
+var listHostPattern []*hostPattern //most specific first, never changed once published in routeSnapshot
+
+func initHost() {
+	onceHost.Do(func() { //singleton
+		initMapHandler()
+		mapHostPattern = make(map[string]*hostPattern)
+	})
+}
+
+// normalizeHost to strip the port and trailing dot from the host and lower case it.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
+
+// addHostPattern to compile and register the host pattern. return the normalized pattern to be used as key.
+// 	Example api.example.com or *.example.com or {tenant}.example.com
+func addHostPattern(pattern string) (string, error) {
+	initHost()
+	hp, err := parseHostPattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	mutexHost.Lock()
+	if _, found := mapHostPattern[hp.Pattern]; found {
+		mutexHost.Unlock()
+		return hp.Pattern, nil
+	}
+	mapHostPattern[hp.Pattern] = hp
+	list := append(append([]*hostPattern(nil), listHostPattern...), hp) //copy as the previous list is read by requests
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Wildcard != b.Wildcard {
+			return !a.Wildcard
+		}
+		if a.ParamCount != b.ParamCount {
+			return a.ParamCount < b.ParamCount
+		}
+		return len(a.Token) > len(b.Token)
+	})
+	listHostPattern = list
+	mutexHost.Unlock()
+
+	mutexHttp.Lock()
+	changeRouteTable(hp.Pattern)
+	mutexHttp.Unlock()
+	return hp.Pattern, nil
+}
+
+// parseHostPattern to compile the host pattern without registering it.
+func parseHostPattern(pattern string) (*hostPattern, error) {
+	pattern = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(pattern), "."))
+	if pattern == "" {
+		return nil, errors.New("empty host pattern")
+	}
+	label := strings.Split(pattern, ".")
+	hp := &hostPattern{Pattern: pattern}
+	if label[0] == "*" {
+		hp.Wildcard = true
+		label = label[1:]
+		if len(label) == 0 {
+			return nil, errors.New("host pattern need at least one label after * " + pattern)
+		}
+	}
+	token, err := parseParamToken(pattern, label)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range token {
+		if value.CatchAll {
+			return nil, errors.New("catch-all placeholder not supported in host pattern " + pattern)
+		}
+		if value.IsParam {
+			hp.ParamCount++
+		}
+	}
+	hp.Token = token
+	return hp, nil
+}
+
+// matchHost to get all the registered host patterns that match the host, most specific first. exact host before {param} host before * wildcard host.
+func matchHost(host string) []hostMatch {
+	return matchSnapshotHost(loadRouteTable(), host)
+}
+
+// matchSnapshotHost to get the host patterns of the routeSnapshot that match the host without taking any lock.
+func matchSnapshotHost(snapshot *routeSnapshot, host string) []hostMatch {
+	host = normalizeHost(host)
+	if host == "" {
+		return nil
+	}
+	label := strings.Split(host, ".")
+	var matched []hostMatch
+	for _, hp := range snapshot.HostPattern {
+		actual := label
+		if hp.Wildcard {
+			if len(label) <= len(hp.Token) {
+				continue
+			}
+			actual = label[len(label)-len(hp.Token):]
+		}
+		if hostParam, found := matchPathParamToken(hp.Token, actual, false); found {
+			matched = append(matched, hostMatch{Pattern: hp.Pattern, HostParam: hostParam})
+		}
+	}
+	return matched
+}
@@ -0,0 +1,82 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func textHandler(text string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, text)
+	})
+}
+
+func serveHostTest(handler http.Handler, method string, host string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, nil)
+	r.Host = host
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestHostPattern(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/host", textHandler("default"))
+		Host("shop.example.com").AddHandler("/host", textHandler("exact"))
+		Host("*.example.com").AddHandler("/host", textHandler("wildcard"))
+		Host("{tenant}.example.com").AddHandlerPlaceholder("/host", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "tenant "+PathParam(r, "tenant"))
+		}))
+		Host("{tenant}.example.com").AddHandler("/host/only", textHandler("tenant only"))
+	})
+	for host, want := range map[string]string{
+		"shop.example.com":      "exact",
+		"SHOP.example.com:8080": "exact",
+		"acme.example.com":      "tenant acme",
+		"a.b.example.com":       "wildcard",
+		"other.test":            "default",
+	} {
+		if w := serveHostTest(handler, http.MethodGet, host, "/host"); w.Body.String() != want {
+			t.Errorf("GET %s/host = %q, want %q", host, w.Body.String(), want)
+		}
+	}
+	if w := serveHostTest(handler, http.MethodGet, "shop.example.com", "/host/only"); w.Body.String() != "tenant only" {
+		t.Errorf("GET shop.example.com/host/only = %q, want the url mapping of the next matching host pattern", w.Body.String())
+	}
+	if w := serveHostTest(handler, http.MethodGet, "other.test", "/host/only"); w.Code != http.StatusNotFound {
+		t.Errorf("GET other.test/host/only = %d, want 404", w.Code)
+	}
+}
+
+func TestHostPatternError(t *testing.T) {
+	for _, pattern := range []string{"", "*", "{*rest}.example.com"} {
+		if _, err := addHostPattern(pattern); err == nil {
+			t.Errorf("addHostPattern(%q) accepted an invalid host pattern", pattern)
+		}
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		api := Group("/group/api/")
+		api.AddHandler("/user", textHandler("user"))
+		api.Group("/v1").AddHandlerPlaceholder("/item/{id:int}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "item "+PathParam(r, "id"))
+		}))
+		api.AddHandlerRegEx("^/report/[0-9]+$", textHandler("report"))
+	})
+	for target, want := range map[string]string{
+		"/group/api/user":       "user",
+		"/group/api/v1/item/12": "item 12",
+		"/group/api/report/3":   "report",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", target, w.Body.String(), want)
+		}
+	}
+	if w := serveTest(handler, http.MethodGet, "/report/3"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /report/3 = %d, want 404 as the group regex is anchored to the prefix", w.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package httpUtil
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Middleware wrap the next http.Handler so code can run before and after it e.g measure latency, read the status code, change response headers or recover from a panic downstream.
+// 	Example
+// 	func Timing(next http.Handler) http.Handler {
+// 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// 			start := time.Now()
+// 			rw := WrapResponseWriter(w)
+// 			next.ServeHTTP(rw, r)
+// 			log.Printf("%s %s %d %v", r.Method, r.URL.Path, rw.Status, time.Since(start))
+// 		})
+// 	}
+type Middleware func(next http.Handler) http.Handler
+
+type middlewareAdapter struct {
+	Middleware Middleware
+}
+
+// chainRunner call the chain one by one until one return false then call Next if all return true.
+type chainRunner struct {
+	Chain []ChainNextHandler
+	Next  http.Handler
+}
+
+// composedHandler is the handler from NewHandler wrapped by the global middleware. swapped atomically on every Use.
+type composedHandler struct {
+	Handler http.Handler
+}
+
+var mutexMiddleware sync.Mutex
+var listMiddleware []Middleware
+var middlewareBase http.Handler
+var middlewareValue atomic.Value
+
+// ResponseWriter wrap http.ResponseWriter to remember the status code and number of bytes written so a Middleware can read them after the next handler return.
+// header written by the next handler can still be changed by a func added with BeforeWriteHeader as it is called just before the header is sent.
+type ResponseWriter struct {
+	http.ResponseWriter
+	Status  int
+	Written int64
+
+	wroteHeader bool
+	beforeWrite []func(w http.ResponseWriter, status int)
+}
+
+// MiddlewareAdapter to put a Middleware inside a []ChainNextHandler for AddChainHandler, AddChainHandlerRegEx, AddChainHandlerPlaceholder. the Middleware wrap the rest of the chain after it.
+// 	Example AddChainHandler("/hello10", []ChainNextHandler{MiddlewareAdapter(Timing), &logic3.Api1Handler{Config: c}, &logic3.Api2Handler{Config: c}}, http.MethodGet)
+func MiddlewareAdapter(middleware Middleware) ChainNextHandler {
+	return &middlewareAdapter{Middleware: middleware}
+}
+
+// ServeNextHTTP is implementation method for the ChainNextHandler interface when the adapter is called outside of a chain. return true if the Middleware called its next handler.
+func (a *middlewareAdapter) ServeNextHTTP(w http.ResponseWriter, r *http.Request) bool {
+	called := false
+	a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(w, r)
+	return called
+}
+
+// ChainNextAdapter to turn a ChainNextHandler e.g rateLimiter.NewTokenBucketHandler(60, 30, 30) into a Middleware. next is called only if ServeNextHTTP return true.
+func ChainNextAdapter(handler ChainNextHandler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handler.ServeNextHTTP(w, r) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// WrapMiddleware to wrap the handler with the middleware. the first middleware is the outermost and run first.
+func WrapMiddleware(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Use to add global middleware wrapping every request of the handler from NewHandler, including url mapping of every host, static files, custom error pages, the I am alive! root, url rewrite and clean path redirect.
+// the order is defined as: the built-in RequestState and Recovery, global middleware in the order added, the first is the outermost and run first, then CleanPathHandler, RewriteUrlHandler, the matched url mapping with its RouteGroup middleware and chain.
+// so global middleware see the original url before it is cleaned or rewritten. it can be called after server startup and apply to the next request.
+// 	Example Use(RequestId, AccessLog, SecurityHeader)
+func Use(middleware ...Middleware) {
+	mutexMiddleware.Lock()
+	defer mutexMiddleware.Unlock()
+	listMiddleware = append(listMiddleware, middleware...)
+	publishMiddleware()
+}
+
+// ListMiddleware to get the func name of the global middleware in the order they run.
+func ListMiddleware() []string {
+	mutexMiddleware.Lock()
+	defer mutexMiddleware.Unlock()
+	var name []string
+	for _, value := range listMiddleware {
+		name = append(name, middlewareName(value))
+	}
+	return name
+}
+
+// setMiddlewareBase to set the handler wrapped by the global middleware and get the handler serving the composed one.
+func setMiddlewareBase(base http.Handler) http.Handler {
+	mutexMiddleware.Lock()
+	defer mutexMiddleware.Unlock()
+	middlewareBase = base
+	publishMiddleware()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middlewareValue.Load().(*composedHandler).Handler.ServeHTTP(w, r)
+	})
+}
+
+// publishMiddleware must be called with mutexMiddleware locked.
+func publishMiddleware() {
+	if middlewareBase == nil {
+		return
+	}
+	middleware := append([]Middleware{stateMiddleware, Recovery}, listMiddleware...) //RequestState is shared by every global middleware and panic in them are recovered
+	middlewareValue.Store(&composedHandler{Handler: WrapMiddleware(middlewareBase, middleware...)})
+}
+
+// composeChain to turn the chain into one http.Handler. a Middleware from MiddlewareAdapter wrap the rest of the chain after it.
+func composeChain(chain []ChainNextHandler) http.Handler {
+	for index, value := range chain {
+		if adapter, found := value.(*middlewareAdapter); found {
+			return &chainRunner{Chain: chain[:index], Next: adapter.Middleware(composeChain(chain[index+1:]))}
+		}
+	}
+	return &chainRunner{Chain: chain}
+}
+
+func (a *chainRunner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w = WrapResponseWriter(w) //shared by every handler of the chain to know if the header has been written
+	for _, value := range a.Chain {
+		if ok := value.ServeNextHTTP(w, r); !ok {
+			return
+		}
+	}
+	if a.Next != nil {
+		a.Next.ServeHTTP(w, r)
+	}
+}
+
+// middlewareName to get the func name of the Middleware for ListRoutes.
+func middlewareName(middleware Middleware) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(middleware).Pointer()); f != nil {
+		return strings.TrimSuffix(f.Name(), "-fm")
+	}
+	return "Middleware"
+}
+
+// WrapResponseWriter to get a ResponseWriter over w. if w is already a ResponseWriter it is returned as it is so nested Middleware share the same status code.
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// BeforeWriteHeader to add a func called with the status code just before the header is sent e.g to set a security header unless the handler set it.
+func (w *ResponseWriter) BeforeWriteHeader(fn func(w http.ResponseWriter, status int)) {
+	w.beforeWrite = append(w.beforeWrite, fn)
+}
+
+// WroteHeader to check if the header has been sent. after that the status code and header cannot be changed.
+func (w *ResponseWriter) WroteHeader() bool {
+	return w.wroteHeader
+}
+
+// WriteHeader is implementation method for the http.ResponseWriter interface.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	for _, fn := range w.beforeWrite {
+		fn(w.ResponseWriter, status)
+	}
+	w.wroteHeader = true
+	w.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write is implementation method for the http.ResponseWriter interface.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.Written += int64(n)
+	return n, err
+}
+
+// Flush is implementation method for the http.Flusher interface.
+func (w *ResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is implementation method for the http.Hijacker interface e.g websocket.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.wroteHeader = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not supported")
+}
+
+// Unwrap to get the wrapped http.ResponseWriter for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
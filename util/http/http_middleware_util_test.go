@@ -0,0 +1,170 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// traceMiddleware to append name before and after the next handler to the X-Trace header.
+func traceMiddleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+			w.Header().Add("X-Trace", "/"+name)
+		})
+	}
+}
+
+// traceHandler to append name to the X-Trace header and write it.
+func traceHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Trace", name)
+		io.WriteString(w, name)
+	})
+}
+
+func traceOf(w *httptest.ResponseRecorder) string {
+	return strings.Join(w.Header().Values("X-Trace"), " ")
+}
+
+func TestGroupMiddlewareOrder(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		api := Group("/api").Use(traceMiddleware("a"), traceMiddleware("b"))
+		api.AddHandler("/one", traceHandler("one"))
+		v1 := api.Group("/v1").Use(traceMiddleware("c"))
+		v1.AddHandler("/two", traceHandler("two"))
+		api.Use(traceMiddleware("late"))
+		api.AddHandler("/three", traceHandler("three"))
+		AddHandler("/plain", traceHandler("plain"))
+	})
+	for target, want := range map[string]string{
+		"/api/one":    "a b one /b /a",
+		"/api/v1/two": "a b c two /c /b /a",
+		"/api/three":  "a b late three /late /b /a",
+		"/plain":      "plain",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); traceOf(w) != want {
+			t.Errorf("GET %s trace = %q, want %q", target, traceOf(w), want)
+		}
+	}
+}
+
+func TestMiddlewareAdapter(t *testing.T) {
+	stop := chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("stop") != "" {
+			http.Error(w, "stopped", http.StatusForbidden)
+			return false
+		}
+		return true
+	})
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddChainHandler("/adapter", []ChainNextHandler{
+			chainTraceHandler("first"),
+			MiddlewareAdapter(traceMiddleware("m")),
+			chainTraceHandler("second"),
+			stop,
+			chainTraceHandler("third"),
+		})
+		Group("/adapter").Use(ChainNextAdapter(stop)).AddHandler("/group", traceHandler("group"))
+	})
+	if w := serveTest(handler, http.MethodGet, "/adapter"); traceOf(w) != "first m second third /m" {
+		t.Errorf("GET /adapter trace = %q, want the middleware to wrap the rest of the chain", traceOf(w))
+	}
+	if w := serveTest(handler, http.MethodGet, "/adapter?stop=1"); w.Code != http.StatusForbidden || traceOf(w) != "first m second /m" {
+		t.Errorf("GET /adapter?stop=1 = %d %q, want 403 and the chain stopped", w.Code, traceOf(w))
+	}
+	if w := serveTest(handler, http.MethodGet, "/adapter/group"); w.Code != http.StatusOK || w.Body.String() != "group" {
+		t.Errorf("GET /adapter/group = %d %q, want 200 group", w.Code, w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/adapter/group?stop=1"); w.Code != http.StatusForbidden || traceOf(w) != "" {
+		t.Errorf("GET /adapter/group?stop=1 = %d %q, want 403 without calling the handler", w.Code, traceOf(w))
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	var status int
+	var written int64
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := WrapResponseWriter(w)
+			if WrapResponseWriter(rw) != rw {
+				t.Error("WrapResponseWriter of a ResponseWriter is not itself")
+			}
+			rw.BeforeWriteHeader(func(w http.ResponseWriter, status int) {
+				if w.Header().Get("X-Frame-Options") == "" {
+					w.Header().Set("X-Frame-Options", "DENY")
+				}
+			})
+			next.ServeHTTP(rw, r)
+			status, written = rw.Status, rw.Written
+		})
+	}
+	handler := newTestHandler(t, newTestConfig(), func() {
+		group := Group("/rw").Use(capture)
+		group.AddHandler("/created", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, "created")
+		}))
+		group.AddHandler("/write", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+			io.WriteString(w, "hello")
+		}))
+	})
+	w := serveTest(handler, http.MethodGet, "/rw/created")
+	if w.Code != http.StatusCreated || status != http.StatusCreated || written != 7 || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("GET /rw/created = %d, captured %d %d bytes, X-Frame-Options %q", w.Code, status, written, w.Header().Get("X-Frame-Options"))
+	}
+	w = serveTest(handler, http.MethodGet, "/rw/write")
+	if status != http.StatusOK || written != 5 || w.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("GET /rw/write captured %d %d bytes, X-Frame-Options %q, want 200 5 SAMEORIGIN", status, written, w.Header().Get("X-Frame-Options"))
+	}
+}
+
+func chainTraceHandler(name string) ChainNextHandler {
+	return chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Add("X-Trace", name)
+		return true
+	})
+}
+
+func TestGlobalMiddlewareOrder(t *testing.T) {
+	var path []string
+	seen := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = append(path, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}
+	c := newTestConfig()
+	c.Site.UrlRewrite = true
+	handler := newTestHandler(t, c, func() {
+		Use(traceMiddleware("g1"), seen)
+		Group("/global").Use(traceMiddleware("group")).AddChainHandler("/route", []ChainNextHandler{MiddlewareAdapter(traceMiddleware("route")), chainTraceHandler("chain")})
+		AddRewriteUrl("/global/old", "/global/route")
+	})
+	Use(traceMiddleware("g2"))
+	if names := ListMiddleware(); len(names) != 3 {
+		t.Errorf("ListMiddleware = %v, want 3 middleware", names)
+	}
+	if w := serveTest(handler, http.MethodGet, "/global/route"); traceOf(w) != "g1 g2 group route chain /route /group /g2 /g1" {
+		t.Errorf("GET /global/route trace = %q, want global then group then route", traceOf(w))
+	}
+	if w := serveTest(handler, http.MethodGet, "/global/old"); w.Code != http.StatusOK || traceOf(w) != "g1 g2 group route chain /route /group /g2 /g1" {
+		t.Errorf("GET /global/old = %d %q, want the rewritten route wrapped by the global middleware", w.Code, traceOf(w))
+	}
+	if w := serveTest(handler, http.MethodGet, "/global/missing"); w.Code != http.StatusNotFound || traceOf(w) != "g1 g2 /g2 /g1" {
+		t.Errorf("GET /global/missing = %d %q, want 404 wrapped by the global middleware", w.Code, traceOf(w))
+	}
+	if len(path) != 3 || path[1] != "/global/old" {
+		t.Errorf("global middleware saw %v, want the url before it is rewritten", path)
+	}
+	Reset()
+	if names := ListMiddleware(); len(names) != 0 {
+		t.Errorf("ListMiddleware after Reset = %v, want none", names)
+	}
+}
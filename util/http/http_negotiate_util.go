@@ -0,0 +1,273 @@
+package httpUtil
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ApiVersionHeader is the request header checked for the api version when the Accept header does not carry one.
+var ApiVersionHeader = "X-Api-Version"
+
+// MediaVariant is one handler of a url mapping that produce and/or consume some media types and serve an api version. Used by Negotiate
+type MediaVariant struct {
+	//media types the handler can respond with e.g application/vnd.acme.v2+json or application/json. empty produce anything
+	Produce []string
+	//media types of the request body the handler accept e.g application/json. empty consume anything
+	Consume []string
+	//api version the handler serve e.g 2. empty serve any version
+	Version string
+
+	Handler          http.Handler
+	ChainNextHandler []ChainNextHandler
+}
+
+type negotiateHandler struct {
+	Variant []MediaVariant
+	//Handler or ChainNextHandler of each variant
+	Serve []http.Handler
+}
+
+type acceptRange struct {
+	MediaType string
+	Quality   float64
+	Param     map[string]string
+}
+
+type negotiatedKey struct{}
+
+type negotiated struct {
+	MediaType string
+	Version   string
+}
+
+var mediaVersionRE = regexp.MustCompile(`\.v(\d+(?:\.\d+)*)(?:\+|$)`)
+
+// Negotiate to get a http.Handler that pick the best MediaVariant for each request. It can be passed to any of the Add* func.
+//
+// variants are first filtered by the request Content-Type against Consume, responding 415 if none left.
+// then by the api version from the Accept header (application/vnd.acme.v2+json or application/vnd.acme+json; version=2) or ApiVersionHeader, responding 406 if none left.
+// a version parameter is matched on the media type without its version so application/vnd.acme+json; version=2 accept the Produce application/vnd.acme.v2+json.
+// last the variant whose Produce has the highest quality in the Accept header is called, responding 406 if none is acceptable. On a tie the earlier variant win so put the default first.
+// 406 and 415 are served by Error as if the variant best for the Accept header, else the first, was chosen so a json api respond them as application/problem+json like the errors of its handlers.
+// the chosen media type is set as Content-Type and can be read with NegotiatedMediaType(r), the api version with ApiVersion(r).
+// 	Example
+// 	AddHandler("/user", Negotiate(
+// 		MediaVariant{Produce: []string{"application/vnd.acme.v2+json"}, Version: "2", Handler: &UserV2Handler{}},
+// 		MediaVariant{Produce: []string{"application/json"}, Version: "1", Handler: &UserV1Handler{}},
+// 	), http.MethodGet)
+func Negotiate(variant ...MediaVariant) http.Handler {
+	handler := &negotiateHandler{Variant: variant}
+	for _, value := range variant {
+		if value.Handler != nil {
+			handler.Serve = append(handler.Serve, value.Handler)
+		} else {
+			handler.Serve = append(handler.Serve, composeChain(value.ChainNextHandler))
+		}
+	}
+	return handler
+}
+
+func (a *negotiateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", ApiVersionHeader)
+
+	var candidate []int
+	for index := range a.Variant {
+		candidate = append(candidate, index)
+	}
+	accept := parseAccept(r.Header.Get("Accept"))
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		requestType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			a.error(w, r, accept, http.StatusUnsupportedMediaType)
+			return
+		}
+		var consume []int
+		for _, index := range candidate {
+			if value := a.Variant[index]; len(value.Consume) == 0 || mediaTypeIn(requestType, value.Consume) {
+				consume = append(consume, index)
+			}
+		}
+		if len(consume) == 0 {
+			a.error(w, r, accept, http.StatusUnsupportedMediaType)
+			return
+		}
+		candidate = consume
+	}
+
+	version := requestApiVersion(r, accept)
+	if version != "" {
+		var versioned []int
+		for _, index := range candidate {
+			if value := a.Variant[index]; value.Version == "" || value.Version == version {
+				versioned = append(versioned, index)
+			}
+		}
+		if len(versioned) == 0 {
+			a.error(w, r, accept, http.StatusNotAcceptable)
+			return
+		}
+		candidate = versioned
+	}
+
+	best, bestType, bestQuality := -1, "", 0.0
+	for _, index := range candidate {
+		mediaType, quality := bestProduce(accept, a.Variant[index].Produce)
+		if quality > bestQuality {
+			best, bestType, bestQuality = index, mediaType, quality
+		}
+	}
+	if best < 0 {
+		a.error(w, r, accept, http.StatusNotAcceptable)
+		return
+	}
+
+	chosen := a.Variant[best]
+	if version == "" {
+		version = chosen.Version
+	}
+	if bestType != "" {
+		w.Header().Set("Content-Type", bestType)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), negotiatedKey{}, &negotiated{MediaType: bestType, Version: version}))
+	a.Serve[best].ServeHTTP(w, r)
+}
+
+// error to serve the status by Error with the media type the url mapping would respond with as the negotiated one so it decide the format the same way as for the errors of the variant handlers.
+func (a *negotiateHandler) error(w http.ResponseWriter, r *http.Request, accept []acceptRange, code int) {
+	mediaType, bestQuality := "", 0.0
+	for _, value := range a.Variant {
+		if produce, quality := bestProduce(accept, value.Produce); quality > bestQuality {
+			mediaType, bestQuality = produce, quality
+		}
+	}
+	if bestQuality == 0 && len(a.Variant) > 0 && len(a.Variant[0].Produce) > 0 { //nothing acceptable, the default variant
+		mediaType = a.Variant[0].Produce[0]
+	}
+	r = r.WithContext(context.WithValue(r.Context(), negotiatedKey{}, &negotiated{MediaType: mediaType}))
+	Error(w, r, http.StatusText(code), code)
+}
+
+// NegotiatedMediaType to get the media type chosen by Negotiate for the response. Return "" if none.
+func NegotiatedMediaType(r *http.Request) string {
+	if value, ok := r.Context().Value(negotiatedKey{}).(*negotiated); ok {
+		return value.MediaType
+	}
+	return ""
+}
+
+// ApiVersion to get the api version chosen by Negotiate. Return "" if none.
+func ApiVersion(r *http.Request) string {
+	if value, ok := r.Context().Value(negotiatedKey{}).(*negotiated); ok {
+		return value.Version
+	}
+	return ""
+}
+
+func requestApiVersion(r *http.Request, accept []acceptRange) string {
+	for _, value := range accept {
+		if version, found := value.Param["version"]; found {
+			return version
+		}
+		if matched := mediaVersionRE.FindStringSubmatch(value.MediaType); matched != nil {
+			return matched[1]
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(ApiVersionHeader))
+}
+
+// parseAccept to parse the Accept header into media ranges sorted by quality, highest first. An empty header is the same as */*
+func parseAccept(header string) []acceptRange {
+	if strings.TrimSpace(header) == "" {
+		return []acceptRange{{MediaType: "*/*", Quality: 1}}
+	}
+	var accept []acceptRange
+	for _, value := range strings.Split(header, ",") {
+		mediaType, param, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, found := param["q"]; found {
+			if f, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = f
+			}
+			delete(param, "q")
+		}
+		accept = append(accept, acceptRange{MediaType: mediaType, Quality: quality, Param: param})
+	}
+	if len(accept) == 0 {
+		return []acceptRange{{MediaType: "*/*", Quality: 1}}
+	}
+	sort.SliceStable(accept, func(i, j int) bool {
+		return accept[i].Quality > accept[j].Quality
+	})
+	return accept
+}
+
+// acceptQuality to get the quality of mediaType by the most specific matching media range. 0 is not acceptable.
+func acceptQuality(accept []acceptRange, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	quality, specific := 0.0, -1
+	for _, value := range accept {
+		level := -1
+		if value.MediaType == mediaType {
+			level = 2
+		} else if value.MediaType == "*/*" {
+			level = 0
+		} else if strings.HasSuffix(value.MediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(value.MediaType, "*")) {
+			level = 1
+		} else if version, found := value.Param["version"]; found { //application/vnd.acme+json; version=2 is application/vnd.acme.v2+json
+			if baseType, mediaVersion := splitMediaVersion(mediaType); baseType == value.MediaType && mediaVersion == version {
+				level = 2
+			}
+		}
+		if level > specific {
+			quality, specific = value.Quality, level
+		}
+	}
+	return quality
+}
+
+// bestProduce to get the produce media type with the highest quality. an empty produce is acceptable by any Accept header with quality of its first media range.
+func bestProduce(accept []acceptRange, produce []string) (string, float64) {
+	if len(produce) == 0 {
+		if len(accept) == 0 {
+			return "", 0
+		}
+		return "", accept[0].Quality
+	}
+	bestType, bestQuality := "", 0.0
+	for _, value := range produce {
+		if quality := acceptQuality(accept, value); quality > bestQuality {
+			bestType, bestQuality = value, quality
+		}
+	}
+	return bestType, bestQuality
+}
+
+// splitMediaVersion to get the media type without its version and the version e.g application/vnd.acme+json and 2 of application/vnd.acme.v2+json
+func splitMediaVersion(mediaType string) (string, string) {
+	index := mediaVersionRE.FindStringSubmatchIndex(mediaType)
+	if index == nil {
+		return mediaType, ""
+	}
+	return mediaType[:index[0]] + mediaType[index[3]:], mediaType[index[2]:index[3]]
+}
+
+func mediaTypeIn(mediaType string, list []string) bool {
+	for _, value := range list {
+		if strings.EqualFold(value, mediaType) {
+			return true
+		}
+		if strings.HasSuffix(value, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(value, "*")) {
+			return true
+		}
+	}
+	return false
+}
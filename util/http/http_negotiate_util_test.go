@@ -0,0 +1,98 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func negotiateTestHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name+" "+NegotiatedMediaType(r)+" v"+ApiVersion(r))
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/negotiate", Negotiate(
+			MediaVariant{Produce: []string{"application/json"}, Consume: []string{"application/json"}, Version: "1", Handler: negotiateTestHandler("json")},
+			MediaVariant{Produce: []string{"application/vnd.acme.v2+json"}, Consume: []string{"application/json"}, Version: "2", Handler: negotiateTestHandler("acme")},
+			MediaVariant{Produce: []string{"text/html"}, Consume: []string{"application/x-www-form-urlencoded"}, Handler: negotiateTestHandler("html")},
+		), http.MethodGet, http.MethodPost)
+	})
+	for name, test := range map[string]struct {
+		Method      string
+		Accept      string
+		Version     string
+		ContentType string
+		Status      int
+		Body        string
+	}{
+		"no accept":             {http.MethodGet, "", "", "", http.StatusOK, "json application/json v1"},
+		"accept html":           {http.MethodGet, "text/html", "", "", http.StatusOK, "html text/html v"},
+		"accept quality":        {http.MethodGet, "application/json;q=0.5, text/html", "", "", http.StatusOK, "html text/html v"},
+		"accept wildcard":       {http.MethodGet, "text/*", "", "", http.StatusOK, "html text/html v"},
+		"version in media type": {http.MethodGet, "application/vnd.acme.v2+json", "", "", http.StatusOK, "acme application/vnd.acme.v2+json v2"},
+		"version parameter":     {http.MethodGet, "application/vnd.acme+json; version=2", "", "", http.StatusOK, "acme application/vnd.acme.v2+json v2"},
+		"version header":        {http.MethodGet, "application/*", "2", "", http.StatusOK, "acme application/vnd.acme.v2+json v2"},
+		"unknown version":       {http.MethodGet, "application/json", "3", "", http.StatusNotAcceptable, ""},
+		"not acceptable":        {http.MethodGet, "image/png", "", "", http.StatusNotAcceptable, ""},
+		"consume":               {http.MethodPost, "", "", "application/x-www-form-urlencoded", http.StatusOK, "html text/html v"},
+		"unsupported media":     {http.MethodPost, "", "", "text/plain", http.StatusUnsupportedMediaType, ""},
+		"invalid content type":  {http.MethodPost, "", "", "a/b/c", http.StatusUnsupportedMediaType, ""},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.Method, "/negotiate", strings.NewReader("x"))
+		for key, value := range map[string]string{"Accept": test.Accept, ApiVersionHeader: test.Version, "Content-Type": test.ContentType} {
+			if value != "" {
+				r.Header.Set(key, value)
+			}
+		}
+		handler.ServeHTTP(w, r)
+		if w.Code != test.Status || (test.Body != "" && w.Body.String() != test.Body) {
+			t.Errorf("%s: %d %q, want %d %q", name, w.Code, w.Body.String(), test.Status, test.Body)
+		}
+		if test.Status == http.StatusOK && w.Header().Get("Content-Type") != strings.Fields(test.Body)[1] {
+			t.Errorf("%s: Content-Type %q, want the negotiated media type", name, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestNegotiateErrorFormat(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/negotiate/api", Negotiate(
+			MediaVariant{Produce: []string{"application/json"}, Consume: []string{"application/json"}, Version: "1", Handler: ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return NotFoundError("")
+			})},
+			MediaVariant{Produce: []string{"application/vnd.acme.v2+json"}, Consume: []string{"application/json"}, Version: "2", Handler: negotiateTestHandler("acme")},
+		), http.MethodGet, http.MethodPost)
+		AddHandler("/negotiate/page", Negotiate(MediaVariant{Produce: []string{"text/html"}, Handler: negotiateTestHandler("html")}))
+	})
+	for name, test := range map[string]struct {
+		Method      string
+		Target      string
+		Accept      string
+		ContentType string
+		Status      int
+		Problem     bool
+	}{
+		"handler error":       {http.MethodGet, "/negotiate/api", "application/json", "", http.StatusNotFound, true},
+		"unknown version":     {http.MethodGet, "/negotiate/api", "application/vnd.acme.v3+json", "", http.StatusNotAcceptable, true},
+		"not acceptable":      {http.MethodGet, "/negotiate/api", "image/png", "", http.StatusNotAcceptable, true},
+		"unsupported media":   {http.MethodPost, "/negotiate/api", "application/vnd.acme.v2+json", "text/plain", http.StatusUnsupportedMediaType, true},
+		"html not acceptable": {http.MethodGet, "/negotiate/page", "image/png", "", http.StatusNotAcceptable, false},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.Method, test.Target, strings.NewReader("x"))
+		r.Header.Set("Accept", test.Accept)
+		if test.ContentType != "" {
+			r.Header.Set("Content-Type", test.ContentType)
+		}
+		handler.ServeHTTP(w, r)
+		if problem := w.Header().Get("Content-Type") == "application/problem+json"; w.Code != test.Status || problem != test.Problem {
+			t.Errorf("%s: %d %q, want %d problem %v", name, w.Code, w.Header().Get("Content-Type"), test.Status, test.Problem)
+		}
+	}
+}
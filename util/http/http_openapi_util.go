@@ -0,0 +1,330 @@
+package httpUtil
+
+import (
+	"encoding"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is the version of the application api written into the info object of the OpenAPI document.
+var OpenAPIVersion = "1.0.0"
+
+// RouteDoc is the optional documentation of a url mapping used to generate the OpenAPI 3 document. see SetRouteDoc
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	//a value of the Go type of the request body e.g UserRequest{} or &UserRequest{}. nil for no request body
+	Request interface{}
+	//http status code to a value of the Go type of the response body e.g {200: []User{}, 404: nil}. empty is a 200 response without schema
+	Response map[int]interface{}
+	//placeholder name to OpenAPI type e.g {"id": "integer"}. default is derived from the placeholder constraint
+	PathParamType map[string]string
+}
+
+type openAPIBuilder struct {
+	Schema map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// SetRouteDoc to attach documentation to an existing url mapping of the default host. Return false if the url mapping cannot be found.
+func SetRouteDoc(urlMapping string, doc RouteDoc) bool {
+	return updateRouteInternal("", func(replacement *httpVerbHandler) bool {
+		replacement.Doc = &doc
+		return true
+	}, urlMapping)
+}
+
+// SetRouteDoc is like the package SetRouteDoc but for url mapping added through the RouteGroup.
+func (g *RouteGroup) SetRouteDoc(urlMapping string, doc RouteDoc) bool {
+	if g.err != nil {
+		return false
+	}
+	return updateRouteInternal(g.host, func(replacement *httpVerbHandler) bool {
+		replacement.Doc = &doc
+		return true
+	}, g.urlMapping(urlMapping), g.urlMappingRegEx(urlMapping))
+}
+
+// GenerateOpenAPI to generate an OpenAPI 3 json document from the url mapping of the default host.
+// direct url and path param url mapping are included. glob and regular expression url mapping cannot be described by OpenAPI and are skipped.
+// media types declared by Negotiate are used as the request and response content types else application/json.
+// it is served on /openapi.json of the admin listener and exported by running tiger -openapi <file>
+func GenerateOpenAPI(title string, version string) ([]byte, error) {
+	builder := &openAPIBuilder{Schema: make(map[string]interface{})}
+	paths := make(map[string]interface{})
+	snapshot := loadRouteTable()
+	var handlers []*httpVerbHandler
+	for _, value := range []map[string]http.Handler{snapshot.Default.MapHandler, snapshot.Default.MapHandlerPathParam} {
+		for _, handler := range value {
+			if handler, found := handler.(*httpVerbHandler); found {
+				handlers = append(handlers, handler)
+			}
+		}
+	}
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].UrlMapping < handlers[j].UrlMapping })
+	for _, handler := range handlers {
+		if handler.routeInfo().Kind == "glob" {
+			continue
+		}
+		path := openAPIPath(handler)
+		item, found := paths[path].(map[string]interface{})
+		if !found {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		for _, verb := range handler.HttpVerb {
+			if verb == http.MethodConnect {
+				continue
+			}
+			item[strings.ToLower(verb)] = builder.operation(handler, verb)
+		}
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": title, "version": version},
+		"paths":   paths,
+	}
+	if len(builder.Schema) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": builder.Schema}
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// OpenAPIHandler to get a http.Handler serving the OpenAPI 3 json document. The document is generated on every request so url mapping added or removed at runtime are reflected.
+// 	Example AddHandler("/openapi.json", OpenAPIHandler(c.Site.Name, OpenAPIVersion))
+func OpenAPIHandler(title string, version string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := GenerateOpenAPI(title, version)
+		if err != nil {
+			log.Print(err)
+			Error(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// openAPIPath to turn a url mapping into an OpenAPI path template e.g /user/:id/{slug:[a-z]+}/{*path} to /user/{id}/{slug}/{path}
+func openAPIPath(handler *httpVerbHandler) string {
+	if handler.MatchKind != matchPathParam {
+		return handler.UrlMapping
+	}
+	var path []string
+	for _, token := range handler.PathToken {
+		if token.IsParam {
+			path = append(path, "{"+token.Name+"}")
+		} else {
+			path = append(path, token.Raw)
+		}
+	}
+	result := "/" + strings.Join(path, "/")
+	if hasTrailingSlash(handler.UrlMapping) && result != "/" {
+		result += "/"
+	}
+	return result
+}
+
+func (b *openAPIBuilder) operation(handler *httpVerbHandler, verb string) map[string]interface{} {
+	doc := handler.Doc
+	if doc == nil {
+		doc = &RouteDoc{}
+	}
+	op := make(map[string]interface{})
+	if handler.Name != "" {
+		op["operationId"] = handler.Name + "_" + strings.ToLower(verb)
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+	if len(doc.Tags) > 0 {
+		op["tags"] = doc.Tags
+	}
+
+	var parameters []interface{}
+	for _, token := range handler.PathToken {
+		if !token.IsParam {
+			continue
+		}
+		schema := map[string]interface{}{"type": "string"}
+		if token.Constraint != nil {
+			switch token.ConstraintName {
+			case "int":
+				schema = map[string]interface{}{"type": "integer"}
+			case "uint":
+				schema = map[string]interface{}{"type": "integer", "minimum": 0}
+			case "uuid":
+				schema = map[string]interface{}{"type": "string", "format": "uuid"}
+			default:
+				schema["pattern"] = token.Constraint.String()
+			}
+		}
+		if paramType, found := doc.PathParamType[token.Name]; found {
+			schema = map[string]interface{}{"type": paramType}
+		}
+		parameters = append(parameters, map[string]interface{}{"name": token.Name, "in": "path", "required": true, "schema": schema})
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	produce, consume := []string{"application/json"}, []string{"application/json"}
+	if negotiate, found := handler.NextHandler.(*negotiateHandler); found {
+		produce, consume = nil, nil
+		for _, variant := range negotiate.Variant {
+			produce = append(produce, variant.Produce...)
+			consume = append(consume, variant.Consume...)
+		}
+		if len(produce) == 0 {
+			produce = []string{"*/*"}
+		}
+		if len(consume) == 0 {
+			consume = []string{"*/*"}
+		}
+	}
+
+	if doc.Request != nil && verb != http.MethodGet && verb != http.MethodHead {
+		content := make(map[string]interface{})
+		for _, mediaType := range consume {
+			content[mediaType] = map[string]interface{}{"schema": b.schema(reflect.TypeOf(doc.Request))}
+		}
+		op["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	}
+
+	responses := make(map[string]interface{})
+	for code, body := range doc.Response {
+		response := map[string]interface{}{"description": http.StatusText(code)}
+		if body != nil {
+			content := make(map[string]interface{})
+			for _, mediaType := range produce {
+				content[mediaType] = map[string]interface{}{"schema": b.schema(reflect.TypeOf(body))}
+			}
+			response["content"] = content
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// schema to get the OpenAPI schema of a Go type. named struct types are put in components and referenced by $ref.
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, found := b.Schema[name]; !found {
+			b.Schema[name] = map[string]interface{}{} //placeholder for recursive type
+			b.Schema[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// schemaName to get the component name of a named type qualified by its package so types of the same name in two packages do not overwrite each other.
+// the characters not allowed in a component name e.g the / of the package path and the [] of a generic type are replaced by _, tiger/logic/user.User is tiger_logic_user.User
+func schemaName(t reflect.Type) string {
+	return strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, t.PkgPath()+"."+t.Name())
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	b.structField(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// structField to add the fields of t into properties following the encoding/json rules for field name, omitempty, - and embedded struct.
+func (b *openAPIBuilder) structField(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, option := tag, ""
+		if index := strings.Index(tag, ","); index >= 0 {
+			name, option = tag[:index], tag[index+1:]
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			b.structField(fieldType, properties, required)
+			continue
+		}
+		if field.PkgPath != "" { //unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(option, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package httpUtil
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPIUser struct {
+	Id      int       `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Created time.Time `json:"created"`
+	Friend  *openAPIUser
+	secret  string
+}
+
+type openAPIPage[T any] struct {
+	Item []T `json:"item"`
+}
+
+// openAPIDoc to generate the OpenAPI document and decode it for the test to walk.
+func openAPIDoc(t *testing.T) map[string]interface{} {
+	t.Helper()
+	b, err := GenerateOpenAPI("tiger", "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// jsonPath to walk the decoded json by the keys separated by space.
+func jsonPath(value interface{}, path string) interface{} {
+	for _, key := range strings.Fields(path) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/openapi/user/{id:int}", textHandler("user"), http.MethodGet, http.MethodPut)
+		AddHandler("/openapi/static/", textHandler("static"))
+		AddHandlerRegEx("^/openapi/r$", textHandler("r"))
+		AddHandler("/openapi/item", Negotiate(MediaVariant{Produce: []string{"application/xml"}, Consume: []string{"text/xml"}, Handler: textHandler("xml")}), http.MethodPost)
+	})
+	SetRouteName("/openapi/user/{id:int}", "user")
+	SetRouteDoc("/openapi/user/{id:int}", RouteDoc{Summary: "user by id", Tags: []string{"user"}, Request: openAPIUser{}, Response: map[int]interface{}{200: &openAPIUser{}, 404: nil}})
+	SetRouteDoc("/openapi/item", RouteDoc{Request: []string{}, Response: map[int]interface{}{201: map[string]int{}, 200: openAPIPage[openAPIUser]{}}})
+	doc := openAPIDoc(t)
+
+	if jsonPath(doc, "info version") != "1.2.3" || jsonPath(doc, "paths /openapi/static/") != nil || jsonPath(doc, "paths ^/openapi/r$") != nil {
+		t.Error("OpenAPI document must have the version and skip glob and regex url mapping")
+	}
+	get := jsonPath(doc, "paths /openapi/user/{id} get")
+	if get == nil || jsonPath(get, "operationId") != "user_get" || jsonPath(get, "summary") != "user by id" || jsonPath(get, "requestBody") != nil {
+		t.Fatalf("GET /openapi/user/{id} = %v", get)
+	}
+	if parameter := jsonPath(get, "parameters").([]interface{})[0]; jsonPath(parameter, "name") != "id" || jsonPath(parameter, "schema type") != "integer" {
+		t.Errorf("GET /openapi/user/{id} parameter = %v, want integer id", parameter)
+	}
+	ref, _ := jsonPath(get, "responses 200 content application/json schema $ref").(string)
+	if jsonPath(get, "responses 404 description") != "Not Found" || ref != "#/components/schemas/tiger_util_http.openAPIUser" {
+		t.Fatalf("GET /openapi/user/{id} responses = %v", jsonPath(get, "responses"))
+	}
+	if jsonPath(doc, "paths /openapi/user/{id} put requestBody content application/json schema $ref") != ref {
+		t.Error("PUT /openapi/user/{id} request body not the openAPIUser schema")
+	}
+	schema := jsonPath(doc, "components schemas "+strings.TrimPrefix(ref, "#/components/schemas/"))
+	if jsonPath(schema, "properties id type") != "integer" || jsonPath(schema, "properties created format") != "date-time" ||
+		jsonPath(schema, "properties Friend $ref") != ref || jsonPath(schema, "properties secret") != nil {
+		t.Errorf("openAPIUser schema = %v", schema)
+	}
+	if required, _ := json.Marshal(jsonPath(schema, "required")); string(required) != `["created","id"]` {
+		t.Errorf("openAPIUser required = %s, want created and id", required)
+	}
+
+	post := jsonPath(doc, "paths /openapi/item post")
+	if jsonPath(post, "requestBody content text/xml schema type") != "array" || jsonPath(post, "responses 201 content application/xml schema type") != "object" {
+		t.Errorf("POST /openapi/item = %v, want the media types of Negotiate", post)
+	}
+	pageRef, _ := jsonPath(post, "responses 200 content application/xml schema $ref").(string)
+	if pageRef != "#/components/schemas/tiger_util_http.openAPIPage_tiger_util_http.openAPIUser_" {
+		t.Errorf("generic schema $ref = %q, want the sanitized name qualified by the package", pageRef)
+	}
+	for name := range jsonPath(doc, "components schemas").(map[string]interface{}) {
+		if !regexp.MustCompile(`^[a-zA-Z0-9._-]+$`).MatchString(name) {
+			t.Errorf("component name %q has characters not allowed by OpenAPI", name)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/openapi.json", OpenAPIHandler("tiger", OpenAPIVersion))
+	})
+	w := serveTest(handler, http.MethodGet, "/openapi.json")
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Header().Get("Content-Type") != "application/json" || jsonPath(doc, "paths /openapi.json get") == nil {
+		t.Errorf("GET /openapi.json = %d %q, want the OpenAPI document listing itself", w.Code, w.Header().Get("Content-Type"))
+	}
+}
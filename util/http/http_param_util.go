@@ -0,0 +1,154 @@
+package httpUtil
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pathParamConstraint contain the named constraints that can be used inside a placeholder e.g {id:int}
+// any other constraint is treated as a regular expression that must match the whole path segment e.g {slug:[a-z-]+}
+var pathParamConstraint = map[string]string{
+	"int":   `[-+]?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[A-Za-z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+var uuidRE = regexp.MustCompile(`^(?:` + pathParamConstraint["uuid"] + `)$`)
+
+type pathParamToken struct {
+	Raw        string
+	Name       string
+	IsParam    bool
+	CatchAll   bool
+	Constraint *regexp.Regexp
+	//named constraint e.g int or the regular expression as written in the url mapping
+	ConstraintName string
+}
+
+func parsePathParamToken(urlMapping string) ([]*pathParamToken, error) {
+	return parseParamToken(urlMapping, splitBySlashToken(urlMapping))
+}
+
+func parseParamToken(urlMapping string, pathToken []string) ([]*pathParamToken, error) {
+	var tokens []*pathParamToken
+	for index, value := range pathToken {
+		token := &pathParamToken{Raw: value}
+		matched := pathParamRE.FindStringSubmatch(value)
+		if matched == nil {
+			tokens = append(tokens, token)
+			continue
+		}
+		token.IsParam = true
+		token.Name = matched[1] + matched[3]
+		if strings.HasPrefix(token.Name, "*") {
+			if index != len(pathToken)-1 {
+				return nil, errors.New("catch-all placeholder must be the last path segment " + urlMapping)
+			}
+			token.CatchAll = true
+			token.Name = token.Name[1:]
+		}
+		if constraint := matched[2]; constraint != "" {
+			token.ConstraintName = constraint
+			if token.CatchAll {
+				return nil, errors.New("catch-all placeholder cannot have constraint " + urlMapping)
+			}
+			if value, found := pathParamConstraint[constraint]; found {
+				constraint = value
+			}
+			re, err := regexp.Compile(`^(?:` + constraint + `)$`)
+			if err != nil {
+				return nil, errors.New("invalid placeholder constraint " + value + " " + err.Error())
+			}
+			token.Constraint = re
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// matchPathParamToken return the placeholder values if the actualToken satisfy all the tokens and their constraints. fold true to compare the non placeholder tokens ignoring case.
+func matchPathParamToken(tokens []*pathParamToken, actualToken []string, fold bool) (map[string]string, bool) {
+	var pathParam = make(map[string]string)
+	for index, token := range tokens {
+		if token.CatchAll { //capture all remaining segments, can be empty
+			if index < len(actualToken) {
+				pathParam[token.Name] = strings.Join(actualToken[index:], "/")
+			} else if index == len(actualToken) {
+				pathParam[token.Name] = ""
+			} else {
+				return nil, false
+			}
+			return pathParam, true
+		}
+		if index >= len(actualToken) {
+			return nil, false
+		}
+		if !token.IsParam {
+			if token.Raw != actualToken[index] && !(fold && strings.EqualFold(token.Raw, actualToken[index])) {
+				return nil, false
+			}
+			continue
+		}
+		if token.Constraint != nil && !token.Constraint.MatchString(actualToken[index]) {
+			return nil, false
+		}
+		pathParam[token.Name] = actualToken[index]
+	}
+	if len(tokens) != len(actualToken) {
+		return nil, false
+	}
+	return pathParam, true
+}
+
+// rankPathParamToken is the specificity of a token, lower is tried first: literal, constrained placeholder, placeholder then catch-all.
+func rankPathParamToken(token *pathParamToken) int {
+	switch {
+	case token.CatchAll:
+		return 3
+	case !token.IsParam:
+		return 0
+	case token.Constraint != nil:
+		return 1
+	}
+	return 2
+}
+
+// lessPathParamToken to sort path param url mapping by specificity, comparing the tokens from the first path segment.
+// 	Example /u/new before /u/{id:int} before /u/{name} before /u/{*rest}
+func lessPathParamToken(a, b []*pathParamToken) bool {
+	for index := 0; index < len(a) && index < len(b); index++ {
+		if rankA, rankB := rankPathParamToken(a[index]), rankPathParamToken(b[index]); rankA != rankB {
+			return rankA < rankB
+		}
+	}
+	return len(a) > len(b)
+}
+
+// ParamInt to get the placeholder value as int. error is returned if the placeholder is missing or not a valid int.
+func ParamInt(pathParam map[string]string, name string) (int, error) {
+	value, found := pathParam[name]
+	if !found {
+		return 0, errors.New("cannot find path param " + name)
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("path param " + name + " is not an int " + value)
+	}
+	return i, nil
+}
+
+// ParamUUID to get the placeholder value as a UUID string e.g 123e4567-e89b-12d3-a456-426614174000. error is returned if the placeholder is missing or not a valid UUID.
+func ParamUUID(pathParam map[string]string, name string) (string, error) {
+	value, found := pathParam[name]
+	if !found {
+		return "", errors.New("cannot find path param " + name)
+	}
+	if !uuidRE.MatchString(value) {
+		return "", errors.New("path param " + name + " is not an uuid " + value)
+	}
+	return strings.ToLower(value), nil
+}
@@ -0,0 +1,95 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func pathParamNameHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+		for _, key := range []string{"id", "name", "slug", "uuid", "rest"} {
+			if value, found := PathParams(r)[key]; found {
+				io.WriteString(w, " "+key+"="+value)
+			}
+		}
+	})
+}
+
+func TestPathParamConstraint(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/int/{id:int}", pathParamNameHandler("int"))
+		AddHandlerPlaceholder("/uint/{id:uint}", pathParamNameHandler("uint"))
+		AddHandlerPlaceholder("/alpha/{name:alpha}", pathParamNameHandler("alpha"))
+		AddHandlerPlaceholder("/uuid/{uuid:uuid}", pathParamNameHandler("uuid"))
+		AddHandlerPlaceholder("/slug/{slug:[a-z-]+}", pathParamNameHandler("slug"))
+		AddHandlerPlaceholder("/files/{*rest}", pathParamNameHandler("files"))
+	})
+	for target, want := range map[string]string{
+		"/int/-12":   "int id=-12",
+		"/int/12a":   "",
+		"/uint/12":   "uint id=12",
+		"/uint/-12":  "",
+		"/alpha/abc": "alpha name=abc",
+		"/alpha/ab1": "",
+		"/uuid/0f8fad5b-d9cb-469f-a165-70867728950e": "uuid uuid=0f8fad5b-d9cb-469f-a165-70867728950e",
+		"/uuid/0f8fad5b":  "",
+		"/slug/go-lang":   "slug slug=go-lang",
+		"/slug/go-lang-1": "",
+		"/files/a/b.txt":  "files rest=a/b.txt",
+		"/files":          "files rest=",
+	} {
+		w := serveTest(handler, http.MethodGet, target)
+		if want == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want 404", target, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want 200 %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestPathParamSpecificity(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandlerPlaceholder("/u/{*rest}", pathParamNameHandler("catchall"))
+		AddHandlerPlaceholder("/u/{name}", pathParamNameHandler("plain"))
+		AddHandlerPlaceholder("/u/{id:int}", pathParamNameHandler("constrained"))
+		AddHandlerPlaceholder("/u/{id}/new", pathParamNameHandler("literal"))
+	})
+	for target, want := range map[string]string{
+		"/u/12":     "constrained id=12",
+		"/u/tiger":  "plain name=tiger",
+		"/u/12/new": "literal id=12",
+		"/u/12/old": "catchall rest=12/old",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", target, w.Body.String(), want)
+		}
+	}
+}
+
+func TestParsePathParamTokenError(t *testing.T) {
+	for _, urlMapping := range []string{"/files/{*path}/x", "/files/{*path:int}", "/u/{id:[a-z}"} {
+		if _, err := parsePathParamToken(urlMapping); err == nil {
+			t.Errorf("parsePathParamToken(%s) accepted an invalid url mapping", urlMapping)
+		}
+	}
+}
+
+func TestLessPathParamToken(t *testing.T) {
+	initMapHandler()
+	ordered := []string{"/u/new", "/u/{id:int}", "/u/{name}/x", "/u/{name}", "/u/{*rest}"}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := parsePathParamToken(ordered[i])
+			b, _ := parsePathParamToken(ordered[j])
+			if got := lessPathParamToken(a, b); got != (i < j) {
+				t.Errorf("lessPathParamToken(%s, %s) = %v, want %v", ordered[i], ordered[j], got, i < j)
+			}
+		}
+	}
+}
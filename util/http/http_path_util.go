@@ -0,0 +1,91 @@
+package httpUtil
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"tiger/config"
+	logUtil "tiger/util/log"
+)
+
+// valid values for the json attribute TrailingSlash in config.json
+const (
+	TrailingSlashStrict   = "strict"   // /hello and /hello/ are different url
+	TrailingSlashStrip    = "strip"    // /hello/ is served by /hello url mapping and vice versa
+	TrailingSlashRedirect = "redirect" // /hello/ is redirected to /hello when only /hello url mapping exist and vice versa, 301 for GET/HEAD else 308 with query string kept
+)
+
+// pathPolicy is how the url path is matched against every url mapping syntax. it is set from config.json by NewServeMux
+type pathPolicy struct {
+	TrailingSlash   string
+	CaseInsensitive bool
+	CleanPath       bool
+}
+
+var currentPathPolicy = pathPolicy{TrailingSlash: TrailingSlashStrict}
+
+func setPathPolicy(c *config.Config) {
+	policy := pathPolicy{TrailingSlash: strings.ToLower(c.Site.TrailingSlash), CaseInsensitive: c.Site.CaseInsensitive, CleanPath: c.Site.CleanPath}
+	if policy.TrailingSlash != TrailingSlashStrip && policy.TrailingSlash != TrailingSlashRedirect {
+		policy.TrailingSlash = TrailingSlashStrict
+	}
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	currentPathPolicy = policy
+	publishRouteTable()
+}
+
+// CleanPathHandler to redirect url path with duplicate slash, /./ or /../ to the cleaned url path when the json attribute CleanPath is true in config.json. query string is kept.
+// NewHandler does not dispatch through the http.ServeMux from NewServeMux as http.ServeMux would otherwise clean the url path itself with 301 for every http verb.
+func CleanPathHandler(c *config.Config, next http.Handler) http.Handler {
+	if !c.Site.CleanPath {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cleaned := cleanPath(r.URL.Path); cleaned != r.URL.Path && r.Method != http.MethodConnect {
+			logUtil.DebugPrintln("clean path " + r.URL.Path + " to " + cleaned)
+			redirectPath(w, r, cleaned)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cleanPath is like path.Clean but keep the trailing slash.
+func cleanPath(urlPath string) string {
+	if urlPath == "" {
+		return "/"
+	}
+	if urlPath[0] != '/' {
+		urlPath = "/" + urlPath
+	}
+	cleaned := path.Clean(urlPath)
+	if strings.HasSuffix(urlPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// redirectPath to redirect to urlPath with the same query string. 301 for GET and HEAD, 308 for other http verb so the method and body are kept.
+func redirectPath(w http.ResponseWriter, r *http.Request, urlPath string) {
+	target := *r.URL
+	target.Path = urlPath
+	target.RawPath = ""
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, target.RequestURI(), code)
+}
+
+func toggleTrailingSlash(urlPath string) string {
+	if strings.HasSuffix(urlPath, "/") {
+		return strings.TrimSuffix(urlPath, "/")
+	}
+	return urlPath + "/"
+}
+
+func hasTrailingSlash(urlPath string) bool {
+	return len(urlPath) > 1 && strings.HasSuffix(urlPath, "/")
+}
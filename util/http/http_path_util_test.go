@@ -0,0 +1,89 @@
+package httpUtil
+
+import (
+	"net/http"
+	"testing"
+	"tiger/config"
+)
+
+// setTestPathPolicy to use the path policy of c until the end of the test.
+func setTestPathPolicy(t *testing.T, c *config.Config) {
+	setPathPolicy(c)
+	t.Cleanup(func() {
+		setPathPolicy(newTestConfig())
+	})
+}
+
+func TestCleanPathDisabled(t *testing.T) {
+	c := newTestConfig()
+	handler := newTestHandler(t, c, func() {
+		AddHandler("/clean/a//b", echoPathHandler(), http.MethodPost)
+	})
+	w := serveTest(handler, http.MethodPost, "/clean/a//b")
+	if w.Code != http.StatusOK || w.Body.String() != "POST /clean/a//b" {
+		t.Errorf("POST /clean/a//b = %d %q, want 200 %q", w.Code, w.Body.String(), "POST /clean/a//b")
+	}
+}
+
+func TestCleanPathEnabled(t *testing.T) {
+	c := newTestConfig()
+	c.Site.CleanPath = true
+	handler := newTestHandler(t, c, func() {
+		AddHandler("/clean/c/d", echoPathHandler())
+	})
+	for method, code := range map[string]int{http.MethodGet: http.StatusMovedPermanently, http.MethodPost: http.StatusPermanentRedirect} {
+		w := serveTest(handler, method, "/clean/c//./d?x=1")
+		if w.Code != code || w.Header().Get("Location") != "/clean/c/d?x=1" {
+			t.Errorf("%s /clean/c//./d?x=1 = %d %q, want %d /clean/c/d?x=1", method, w.Code, w.Header().Get("Location"), code)
+		}
+	}
+	if w := serveTest(handler, http.MethodGet, "/clean/c/d"); w.Code != http.StatusOK {
+		t.Errorf("GET /clean/c/d = %d, want 200", w.Code)
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/slash/hello", echoPathHandler())
+		AddHandlerPlaceholder("/slash/u/{id}/", echoPathHandler())
+	})
+	for policy, want := range map[string]map[string]int{
+		TrailingSlashStrict:   {"/slash/hello": http.StatusOK, "/slash/hello/": http.StatusNotFound, "/slash/u/1": http.StatusNotFound},
+		TrailingSlashStrip:    {"/slash/hello": http.StatusOK, "/slash/hello/": http.StatusOK, "/slash/u/1": http.StatusOK},
+		TrailingSlashRedirect: {"/slash/hello": http.StatusOK, "/slash/hello/": http.StatusMovedPermanently, "/slash/u/1": http.StatusMovedPermanently},
+	} {
+		c := newTestConfig()
+		c.Site.TrailingSlash = policy
+		setTestPathPolicy(t, c)
+		for target, code := range want {
+			if w := serveTest(handler, http.MethodGet, target); w.Code != code {
+				t.Errorf("%s: GET %s = %d, want %d", policy, target, w.Code, code)
+			}
+		}
+		if w := serveTest(handler, http.MethodGet, "/slash/u/1"); policy == TrailingSlashRedirect && w.Header().Get("Location") != "/slash/u/1/" {
+			t.Errorf("%s: GET /slash/u/1 Location = %q, want /slash/u/1/", policy, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCaseInsensitivePolicy(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/case/hello", echoPathHandler())
+		AddHandlerPlaceholder("/case/u/{id}", echoPathHandler())
+		AddHandlerRegEx("^/case/r/[0-9]+$", echoPathHandler())
+	})
+	targets := []string{"/case/HELLO", "/Case/U/1", "/CASE/R/1"}
+	for _, target := range targets {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != http.StatusNotFound {
+			t.Errorf("case sensitive: GET %s = %d, want 404", target, w.Code)
+		}
+	}
+	c := newTestConfig()
+	c.Site.CaseInsensitive = true
+	setTestPathPolicy(t, c)
+	for _, target := range targets {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != http.StatusOK {
+			t.Errorf("case insensitive: GET %s = %d, want 200", target, w.Code)
+		}
+	}
+}
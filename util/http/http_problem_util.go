@@ -0,0 +1,224 @@
+package httpUtil
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Problem is the RFC 7807 problem details document written as application/problem+json by Error, NotFound, WriteError and WriteProblem when the client prefer json.
+// Type is a URI identifying the problem type, default about:blank. a relative Type e.g "out-of-credit" is resolved against the TypeBase of the ProblemConfig.
+// Title default to the status text and Instance to the request path. Extension are written as extra top level members e.g "balance": 30.
+type Problem struct {
+	Type         string
+	Title        string
+	Status       int
+	Detail       string
+	Instance     string
+	InvalidParam []InvalidParam
+	Extension    map[string]interface{}
+}
+
+// InvalidParam is one validation detail of a Problem written in the "invalid-params" member.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ProblemConfig is the default of the problem documents of a RouteGroup, set by SetProblemConfig.
+type ProblemConfig struct {
+	//base URI of relative problem Type e.g https://example.com/problems/
+	TypeBase string
+	//true to write problem+json when the client accept json and html equally e.g Accept */* or no Accept header, for api url mapping
+	Default bool
+	//members added to every problem document e.g {"service": "billing"}
+	Extension map[string]interface{}
+}
+
+type problemRule struct {
+	Host   string
+	Prefix string
+	Config ProblemConfig
+}
+
+var mutexProblem sync.RWMutex
+var listProblemRule []problemRule
+
+// NewProblem to get a Problem with the status code and detail shown to the client.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Status: status, Detail: detail}
+}
+
+// AddInvalidParam to add a validation detail and get the same Problem.
+// 	Example WriteProblem(w, r, NewProblem(http.StatusBadRequest, "invalid order").AddInvalidParam("quantity", "must be positive"))
+func (p *Problem) AddInvalidParam(name string, reason string) *Problem {
+	p.InvalidParam = append(p.InvalidParam, InvalidParam{Name: name, Reason: reason})
+	return p
+}
+
+// MarshalJSON is implementation method for the json.Marshaler interface. Extension are flattened and cannot override the standard members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	member := make(map[string]interface{}, len(p.Extension)+6)
+	for key, value := range p.Extension {
+		member[key] = value
+	}
+	member["type"] = p.Type
+	member["title"] = p.Title
+	member["status"] = p.Status
+	if p.Detail != "" {
+		member["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		member["instance"] = p.Instance
+	}
+	if len(p.InvalidParam) > 0 {
+		member["invalid-params"] = p.InvalidParam
+	}
+	return json.Marshal(member)
+}
+
+// SetProblemConfig to set the default of the problem documents for every request.
+func SetProblemConfig(config ProblemConfig) {
+	setProblemConfigInternal("", "", config)
+}
+
+// SetProblemConfig is like the package SetProblemConfig but only used for request matching the RouteGroup host pattern and prefix, including url path with no url mapping.
+// the RouteGroup with the longest matching prefix is used.
+// 	Example Group("/api").SetProblemConfig(ProblemConfig{TypeBase: "https://example.com/problems/", Default: true})
+func (g *RouteGroup) SetProblemConfig(config ProblemConfig) *RouteGroup {
+	if g.err == nil {
+		setProblemConfigInternal(g.host, g.prefix, config)
+	}
+	return g
+}
+
+func setProblemConfigInternal(host string, prefix string, config ProblemConfig) {
+	mutexProblem.Lock()
+	defer mutexProblem.Unlock()
+	for index, value := range listProblemRule {
+		if value.Host == host && value.Prefix == prefix {
+			listProblemRule[index].Config = config
+			return
+		}
+	}
+	listProblemRule = append(listProblemRule, problemRule{Host: host, Prefix: prefix, Config: config})
+}
+
+// getProblemConfig to get the ProblemConfig with the longest prefix of the most specific matching host, the default host last. nil if none.
+func getProblemConfig(r *http.Request) *ProblemConfig {
+	var host []string
+	for _, hm := range matchHost(r.Host) {
+		host = append(host, hm.Pattern)
+	}
+	host = append(host, "")
+	mutexProblem.RLock()
+	defer mutexProblem.RUnlock()
+	for _, h := range host {
+		var best *problemRule
+		for index, value := range listProblemRule {
+			if value.Host != h || (value.Prefix != "" && r.URL.Path != value.Prefix && !strings.HasPrefix(r.URL.Path, value.Prefix+"/")) {
+				continue
+			}
+			if best == nil || len(value.Prefix) > len(best.Prefix) {
+				best = &listProblemRule[index]
+			}
+		}
+		if best != nil {
+			config := best.Config
+			return &config
+		}
+	}
+	return nil
+}
+
+// preferProblem to check if the error response should be a problem document: Negotiate chose a json media type,
+// the Accept header rate application/problem+json or application/json above text/html, or equally with ProblemConfig Default.
+func preferProblem(r *http.Request, config *ProblemConfig) bool {
+	if mediaType := NegotiatedMediaType(r); strings.HasSuffix(mediaType, "json") {
+		return true
+	}
+	accept := parseAccept(r.Header.Get("Accept"))
+	problem := acceptQuality(accept, "application/problem+json")
+	if quality := acceptQuality(accept, "application/json"); quality > problem {
+		problem = quality
+	}
+	html := acceptQuality(accept, "text/html")
+	return problem > html || (problem > 0 && problem == html && config != nil && config.Default)
+}
+
+// WriteProblem to write the problem as application/problem+json if the client prefer json, else Detail (or Title) is served by Error so the custom error page is used.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	message := problem.Detail
+	if message == "" {
+		message = problem.Title
+	}
+	if message == "" {
+		message = http.StatusText(problem.Status)
+	}
+	reportError(w, r, problem.Status, errors.New(message), false, nil)
+	config := getProblemConfig(r)
+	if preferProblem(r, config) {
+		writeProblem(w, r, problem, config)
+		return
+	}
+	errorInternal(w, r, message, problem.Status)
+}
+
+// writeProblem to fill the default of a copy of the problem and write it.
+func writeProblem(w http.ResponseWriter, r *http.Request, problem *Problem, config *ProblemConfig) {
+	p := *problem
+	if p.Status < 400 || p.Status > 599 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	} else if config != nil && config.TypeBase != "" && !strings.Contains(p.Type, ":") {
+		p.Type = strings.TrimSuffix(config.TypeBase, "/") + "/" + strings.TrimPrefix(p.Type, "/")
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	extension := make(map[string]interface{})
+	if config != nil {
+		for key, value := range config.Extension {
+			extension[key] = value
+		}
+	}
+	if id := requestId(w, r); id != "" {
+		extension["requestId"] = id
+	}
+	for key, value := range problem.Extension {
+		extension[key] = value
+	}
+	p.Extension = extension
+	p.InvalidParam = append([]InvalidParam(nil), problem.InvalidParam...)
+	sort.SliceStable(p.InvalidParam, func(i, j int) bool {
+		return p.InvalidParam[i].Name < p.InvalidParam[j].Name
+	})
+	b, err := json.Marshal(&p)
+	if err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(b)
+}
+
+// requestId to get the X-Request-Id set by a middleware on the response, else sent by the client.
+func requestId(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-Id")
+}
@@ -0,0 +1,93 @@
+package httpUtil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveAcceptTest to serve the request with the Accept header.
+func serveAcceptTest(handler http.Handler, target string, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestProblemNegotiation(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		Group("/api").SetProblemConfig(ProblemConfig{TypeBase: "https://example.com/problems/", Default: true, Extension: map[string]interface{}{"service": "billing"}})
+		AddHandler("/api/credit", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return &HttpError{Status: http.StatusForbidden, Message: "out of credit", Type: "out-of-credit", Extension: map[string]interface{}{"balance": 30}}
+		}))
+		AddHandler("/page/error", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "db down", http.StatusServiceUnavailable)
+		}))
+	})
+	for name, test := range map[string]struct {
+		Target      string
+		Accept      string
+		Status      int
+		ContentType string
+	}{
+		"html":            {"/page/error", "text/html,application/json;q=0.9", http.StatusServiceUnavailable, "text/plain; charset=utf-8"},
+		"json":            {"/page/error", "application/json", http.StatusServiceUnavailable, "application/problem+json"},
+		"problem":         {"/page/missing", "application/problem+json, text/html;q=0.5", http.StatusNotFound, "application/problem+json"},
+		"any":             {"/page/missing", "*/*", http.StatusNotFound, "text/plain; charset=utf-8"},
+		"any api default": {"/api/missing", "*/*", http.StatusNotFound, "application/problem+json"},
+		"no accept api":   {"/api/credit", "", http.StatusForbidden, "application/problem+json"},
+		"html api":        {"/api/credit", "text/html", http.StatusForbidden, "text/plain; charset=utf-8"},
+	} {
+		w := serveAcceptTest(handler, test.Target, test.Accept)
+		if w.Code != test.Status || w.Header().Get("Content-Type") != test.ContentType {
+			t.Errorf("%s: GET %s = %d %q, want %d %q", name, test.Target, w.Code, w.Header().Get("Content-Type"), test.Status, test.ContentType)
+		}
+	}
+
+	w := serveAcceptTest(handler, "/api/credit", "application/json")
+	var problem map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"type":     "https://example.com/problems/out-of-credit",
+		"title":    "Forbidden",
+		"status":   float64(http.StatusForbidden),
+		"detail":   "out of credit",
+		"instance": "/api/credit",
+		"service":  "billing",
+		"balance":  float64(30),
+	} {
+		if problem[key] != want {
+			t.Errorf("problem %s = %v, want %v", key, problem[key], want)
+		}
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/problem", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "req-1")
+			WriteProblem(w, r, NewProblem(http.StatusBadRequest, "invalid order").AddInvalidParam("quantity", "must be positive").AddInvalidParam("item", "is required"))
+		}))
+		AddHandler("/problem/field", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return BadRequestError(http.StatusText(http.StatusBadRequest), map[string]string{"email": "is required"})
+		}))
+	})
+	w := serveAcceptTest(handler, "/problem", "application/json")
+	want := `{"detail":"invalid order","instance":"/problem","invalid-params":[{"name":"item","reason":"is required"},{"name":"quantity","reason":"must be positive"}],"requestId":"req-1","status":400,"title":"Bad Request","type":"about:blank"}`
+	if w.Code != http.StatusBadRequest || w.Body.String() != want {
+		t.Errorf("GET /problem = %d %s, want 400 %s", w.Code, w.Body.String(), want)
+	}
+	if w := serveAcceptTest(handler, "/problem", "text/html"); w.Code != http.StatusBadRequest || w.Body.String() != "invalid order\n" {
+		t.Errorf("GET /problem as html = %d %q, want 400 invalid order", w.Code, w.Body.String())
+	}
+	want = `{"instance":"/problem/field","invalid-params":[{"name":"email","reason":"is required"}],"status":400,"title":"Bad Request","type":"about:blank"}`
+	if w := serveAcceptTest(handler, "/problem/field", "application/json"); w.Body.String() != want {
+		t.Errorf("GET /problem/field = %s, want %s", w.Body.String(), want)
+	}
+}
@@ -0,0 +1,586 @@
+package httpUtil
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	clientUtil "tiger/util/client"
+	logUtil "tiger/util/log"
+	"time"
+)
+
+// how a ProxyRoute pick the upstream of a request
+const (
+	//each upstream in turn, the default
+	ProxyRoundRobin = "roundrobin"
+	//the upstream with the fewest requests in progress
+	ProxyLeastConn = "leastconn"
+	//the same HashHeader or HashCookie value always go to the same upstream while it is healthy, round robin without the value
+	ProxyHash = "hash"
+)
+
+// ProxyRoute is one url prefix forwarded to a pool of upstream servers. see AddProxyRoute
+type ProxyRoute struct {
+	//url prefix e.g /billing/
+	Prefix string
+	//upstream base url e.g http://10.0.0.1:8080 or http://billing:8080/api, the forwarded path is appended to its path
+	Upstream []string
+	//ProxyRoundRobin (default), ProxyLeastConn or ProxyHash
+	Balance string
+	//request header or cookie whose value is hashed by ProxyHash, HashHeader is used if both are set
+	HashHeader string
+	HashCookie string
+	//TimeoutSec to connect and get the response header of one try, default 30. RetryTimes is the number of tries of an idempotent request without body, each on another upstream, default 1. WaitBeforeRetrySec between tries
+	RequestInfo clientUtil.RequestInfo
+	//active health check of every upstream, none if Path is empty
+	HealthCheck ProxyHealthCheck
+	//consecutive failures (connection error or 502 503 504) to eject an upstream for EjectSec, default 3 and 30
+	MaxFail  int
+	EjectSec int
+	//remove Prefix from the forwarded path e.g /billing/invoice/1 is forwarded as /invoice/1, the Prefix is sent in X-Forwarded-Prefix
+	StripPrefix bool
+	//AddRewriteUrl syntax source url to target url applied in order to the forwarded path after StripPrefix, first match win
+	PathRewrite [][2]string
+	//send the Host header of the request instead of the upstream host
+	PreserveHost bool
+	//keep the Forwarded and X-Forwarded-* headers sent by the client e.g behind a trusted load balancer, else they are replaced
+	TrustForwarded bool
+}
+
+// ProxyHealthCheck is the active health check of a ProxyRoute. an upstream is healthy when Path answer 2xx or 3xx within TimeoutSec.
+type ProxyHealthCheck struct {
+	//path requested on every upstream e.g /health
+	Path string
+	//default 10
+	IntervalSec int
+	//default 2
+	TimeoutSec int
+}
+
+// ProxyUpstreamStat is the state of one upstream of a ProxyRoute.
+type ProxyUpstreamStat struct {
+	Prefix   string    `json:"prefix"`
+	Upstream string    `json:"upstream"`
+	Healthy  bool      `json:"healthy"`
+	Ejected  time.Time `json:"ejected"`
+	Active   int64     `json:"active"`
+	Fail     int       `json:"fail"`
+}
+
+type proxyUpstream struct {
+	Url *url.URL
+
+	active     int64 //atomic
+	mutex      sync.Mutex
+	down       bool //by the active health check
+	fail       int
+	ejectUntil time.Time
+}
+
+type proxyHandler struct {
+	Route    ProxyRoute
+	Host     string
+	Upstream []*proxyUpstream
+	Rewrite  *rewriteEngine
+	Proxy    *httputil.ReverseProxy
+	//hash ring of ProxyHash, sorted by Hash
+	Ring []proxyRingNode
+
+	next      uint64 //atomic round robin counter
+	transport *http.Transport
+	stop      chan struct{}
+}
+
+type proxyRingNode struct {
+	Hash  uint32
+	Index int
+}
+
+type proxyTransport struct {
+	handler *proxyHandler
+}
+
+// proxyBodyCloser to count the request in progress on the upstream until the response body is closed.
+type proxyBodyCloser struct {
+	io.ReadCloser
+	once     sync.Once
+	upstream *proxyUpstream
+}
+
+var adminProxyTemplate = template.Must(template.New("proxy").Parse(`<!DOCTYPE html>
+<html><head><title>proxy</title></head><body>
+<h1>proxy upstreams</h1>
+<table border="1" cellpadding="4">
+<tr><th>prefix</th><th>upstream</th><th>healthy</th><th>active</th><th>fail</th><th>ejected until</th></tr>
+{{range .}}<tr><td>{{.Prefix}}</td><td>{{.Upstream}}</td><td>{{.Healthy}}</td><td>{{.Active}}</td><td>{{.Fail}}</td><td>{{if not .Ejected.IsZero}}{{.Ejected.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+var errNoUpstream = errors.New("no healthy upstream")
+var mutexProxy sync.Mutex
+var listProxyHandler []*proxyHandler
+var proxyHttpVerb = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// AddProxyRoute to forward every request under the url prefix of the default host to the upstream pool.
+// 	Example
+// 	AddProxyRoute(ProxyRoute{Prefix: "/billing/", Upstream: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}, Balance: ProxyLeastConn, StripPrefix: true,
+// 		RequestInfo: clientUtil.RequestInfo{TimeoutSec: 5, RetryTimes: 2}, HealthCheck: ProxyHealthCheck{Path: "/health"}})
+// 	AddProxyRoute(ProxyRoute{Prefix: "/cart/", Upstream: []string{"http://cart1", "http://cart2"}, Balance: ProxyHash, HashCookie: "session",
+// 		PathRewrite: [][2]string{{"/cart/item/{id}", "/v2/items/{id}"}}})
+// the X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto headers are set, hop-by-hop headers are removed and upgrade e.g websocket are supported.
+// an upstream answering 502 503 504 or unreachable count as a failure, a request no upstream can serve get 502, 503 when none is healthy and 504 on timeout through Error.
+func AddProxyRoute(route ProxyRoute) error {
+	handler, err := newProxyHandler("", route)
+	if err != nil {
+		return err
+	}
+	addProxyHandler(handler)
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	addHandlerInternal(&httpVerbHandler{UrlMapping: handler.Route.Prefix, MatchKind: matchPath, NextHandler: handler}, proxyHttpVerb...)
+	return nil
+}
+
+// AddProxyRoute is like the package AddProxyRoute but bound to the RouteGroup. the group prefix is put in front of the route prefix.
+func (g *RouteGroup) AddProxyRoute(route ProxyRoute) error {
+	if g.err != nil {
+		return g.err
+	}
+	route.Prefix = g.urlMapping(route.Prefix)
+	handler, err := newProxyHandler(g.host, route)
+	if err != nil {
+		return err
+	}
+	addProxyHandler(handler)
+	g.add(&httpVerbHandler{UrlMapping: handler.Route.Prefix, MatchKind: matchPath, NextHandler: handler}, proxyHttpVerb...)
+	return nil
+}
+
+// ProxyStats to get the state of every upstream of the proxy routes.
+func ProxyStats() []ProxyUpstreamStat {
+	mutexProxy.Lock()
+	handler := append([]*proxyHandler(nil), listProxyHandler...)
+	mutexProxy.Unlock()
+	var stat []ProxyUpstreamStat
+	now := time.Now()
+	for _, h := range handler {
+		for _, u := range h.Upstream {
+			u.mutex.Lock()
+			value := ProxyUpstreamStat{Prefix: h.Route.Prefix, Upstream: u.Url.String(), Healthy: u.availableLocked(now), Active: atomic.LoadInt64(&u.active), Fail: u.fail}
+			if now.Before(u.ejectUntil) {
+				value.Ejected = u.ejectUntil
+			}
+			u.mutex.Unlock()
+			stat = append(stat, value)
+		}
+	}
+	return stat
+}
+
+// ProxyStatsHandler to get the handler of the /proxy admin url listing the upstreams of every proxy route. ?format=json or ?format=html else decided by the Accept header
+func ProxyStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAdmin(w, r, adminProxyTemplate, ProxyStats())
+	})
+}
+
+func newProxyHandler(host string, route ProxyRoute) (*proxyHandler, error) {
+	if !strings.HasPrefix(route.Prefix, "/") {
+		route.Prefix = "/" + route.Prefix
+	}
+	if !strings.HasSuffix(route.Prefix, "/") {
+		route.Prefix += "/"
+	}
+	if len(route.Upstream) == 0 {
+		return nil, errors.New("error proxy route " + route.Prefix + " has no upstream")
+	}
+	switch route.Balance {
+	case "":
+		route.Balance = ProxyRoundRobin
+	case ProxyRoundRobin, ProxyLeastConn:
+	case ProxyHash:
+		if route.HashHeader == "" && route.HashCookie == "" {
+			return nil, errors.New("error proxy route " + route.Prefix + " need HashHeader or HashCookie for " + ProxyHash)
+		}
+	default:
+		return nil, errors.New("error balance " + route.Balance + " of proxy route " + route.Prefix)
+	}
+	if route.MaxFail == 0 {
+		route.MaxFail = 3
+	}
+	if route.EjectSec == 0 {
+		route.EjectSec = 30
+	}
+	if route.RequestInfo.TimeoutSec == 0 { //else an unresponsive upstream would hold the request forever
+		route.RequestInfo.TimeoutSec = 30
+	}
+	handler := &proxyHandler{Route: route, Host: host, stop: make(chan struct{})}
+	for _, value := range route.Upstream {
+		u, err := url.Parse(strings.TrimSpace(value))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("error upstream " + value + " of proxy route " + route.Prefix)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+		handler.Upstream = append(handler.Upstream, &proxyUpstream{Url: u})
+	}
+	for _, value := range route.PathRewrite {
+		compiled, err := compileRewriteRule("", RewriteRule{SourceUrl: value[0], TargetUrl: value[1], Last: true})
+		if err != nil {
+			return nil, errors.New("error " + err.Error() + " of proxy route " + route.Prefix)
+		}
+		handler.Rewrite = handler.Rewrite.with(compiled, -1)
+	}
+	if route.Balance == ProxyHash {
+		for index, value := range handler.Upstream {
+			for replica := 0; replica < 100; replica++ { //virtual nodes to spread the keys evenly
+				handler.Ring = append(handler.Ring, proxyRingNode{Hash: crc32.ChecksumIEEE([]byte(value.Url.String() + "#" + strconv.Itoa(replica))), Index: index})
+			}
+		}
+		sort.Slice(handler.Ring, func(i, j int) bool {
+			return handler.Ring[i].Hash < handler.Ring[j].Hash
+		})
+	}
+
+	timeout := time.Duration(route.RequestInfo.TimeoutSec) * time.Second
+	handler.transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+	}
+	handler.Proxy = &httputil.ReverseProxy{
+		Rewrite:      handler.rewrite,
+		Transport:    &proxyTransport{handler: handler},
+		ErrorHandler: handler.errorHandler,
+	}
+	return handler, nil
+}
+
+// addProxyHandler to start the health check of the handler. a proxy route with the same host and prefix is replaced and its health check stopped.
+func addProxyHandler(handler *proxyHandler) {
+	mutexProxy.Lock()
+	defer mutexProxy.Unlock()
+	handler.startHealthCheck()
+	for index, value := range listProxyHandler {
+		if value.Host == handler.Host && value.Route.Prefix == handler.Route.Prefix {
+			close(value.stop)
+			listProxyHandler[index] = handler
+			return
+		}
+	}
+	listProxyHandler = append(listProxyHandler, handler)
+}
+
+// removeProxyHandler to stop the health check of the proxy route served by the url mapping being removed or replaced and forget it.
+func removeProxyHandler(handler *httpVerbHandler) {
+	proxy, ok := handler.NextHandler.(*proxyHandler)
+	if !ok {
+		return
+	}
+	mutexProxy.Lock()
+	defer mutexProxy.Unlock()
+	for index, value := range listProxyHandler {
+		if value == proxy {
+			close(value.stop)
+			value.transport.CloseIdleConnections()
+			listProxyHandler = append(listProxyHandler[:index], listProxyHandler[index+1:]...)
+			return
+		}
+	}
+}
+
+// stopProxyHandler to stop every health check and forget the proxy routes. used by Reset.
+func stopProxyHandler() {
+	mutexProxy.Lock()
+	defer mutexProxy.Unlock()
+	for _, value := range listProxyHandler {
+		close(value.stop)
+		value.transport.CloseIdleConnections()
+	}
+	listProxyHandler = nil
+}
+
+func (a *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.Proxy.ServeHTTP(w, r)
+}
+
+// rewrite to build the forwarded request without the upstream, set by proxyTransport for each try.
+func (a *proxyHandler) rewrite(pr *httputil.ProxyRequest) {
+	if a.Route.TrustForwarded {
+		for _, name := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+			if value, found := pr.In.Header[name]; found {
+				pr.Out.Header[name] = value
+			}
+		}
+	}
+	pr.SetXForwarded()
+	if a.Route.TrustForwarded { //SetXForwarded replace them with the values of this hop
+		for _, name := range []string{"X-Forwarded-Host", "X-Forwarded-Proto"} {
+			if value := pr.In.Header.Get(name); value != "" {
+				pr.Out.Header.Set(name, value)
+			}
+		}
+	}
+
+	path, rawPath := pr.In.URL.Path, pr.In.URL.RawPath
+	if a.Route.StripPrefix {
+		path = "/" + strings.TrimPrefix(path, a.Route.Prefix)
+		rawPath = ""
+		pr.Out.Header.Set("X-Forwarded-Prefix", strings.TrimSuffix(a.Route.Prefix, "/"))
+	}
+	result := rewriteResult{Url: path, RawQuery: pr.In.URL.RawQuery}
+	a.Rewrite.apply(pr.In, &result)
+	if result.Url != pr.In.URL.Path {
+		rawPath = ""
+	}
+	pr.Out.URL.Path, pr.Out.URL.RawPath, pr.Out.URL.RawQuery = result.Url, rawPath, result.RawQuery
+	if !a.Route.PreserveHost {
+		pr.Out.Host = ""
+	}
+}
+
+// errorHandler to respond through Error so the custom error pages and problem documents are used.
+func (a *proxyHandler) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil { //client gone
+		return
+	}
+	logUtil.ErrorPrintf("error proxy %s %s: %v", r.Method, r.URL.Path, err)
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoUpstream):
+		Error(w, r, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		Error(w, r, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+	default:
+		Error(w, r, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	}
+}
+
+// RoundTrip is implementation method for the http.RoundTripper interface. it pick an upstream for each try and retry idempotent request without body on another upstream.
+func (t *proxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	a := t.handler
+	tries := a.Route.RequestInfo.RetryTimes
+	if tries < 1 || !proxyRetryable(r) {
+		tries = 1
+	}
+	tried := make([]bool, len(a.Upstream))
+	var lastErr error
+	for try := 1; ; try++ {
+		index := a.pick(r, tried)
+		if index < 0 {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, errNoUpstream
+		}
+		tried[index] = true
+		upstream := a.Upstream[index]
+		out := r.Clone(r.Context())
+		out.URL.Scheme, out.URL.Host = upstream.Url.Scheme, upstream.Url.Host
+		if out.URL.RawPath != "" {
+			out.URL.RawPath = upstream.Url.EscapedPath() + out.URL.RawPath
+		}
+		out.URL.Path = upstream.Url.Path + out.URL.Path
+		if out.Host == "" {
+			out.Host = upstream.Url.Host
+		}
+
+		atomic.AddInt64(&upstream.active, 1)
+		resp, err := a.transport.RoundTrip(out)
+		failed := err != nil || resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout
+		if err != nil && r.Context().Err() != nil { //client gone, not the upstream fault
+			atomic.AddInt64(&upstream.active, -1)
+			return nil, err
+		}
+		upstream.report(failed, a.Route.MaxFail, time.Duration(a.Route.EjectSec)*time.Second)
+		if !failed || try >= tries {
+			if err != nil {
+				atomic.AddInt64(&upstream.active, -1)
+				return nil, err
+			}
+			if resp.StatusCode == http.StatusSwitchingProtocols { //the upgraded body must stay an io.ReadWriteCloser
+				atomic.AddInt64(&upstream.active, -1)
+				return resp, nil
+			}
+			resp.Body = &proxyBodyCloser{ReadCloser: resp.Body, upstream: upstream}
+			return resp, nil
+		}
+		atomic.AddInt64(&upstream.active, -1)
+		if err == nil {
+			resp.Body.Close()
+			lastErr = errors.New("upstream " + upstream.Url.Host + " answered " + resp.Status)
+		} else {
+			lastErr = err
+		}
+		logUtil.DebugPrintln("proxy retry " + r.Method + " " + r.URL.Path + " after " + lastErr.Error())
+		if wait := a.Route.RequestInfo.WaitBeforeRetrySec; wait > 0 {
+			select {
+			case <-time.After(time.Duration(wait) * time.Second):
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+		}
+	}
+}
+
+// proxyRetryable to check the request can be sent again: idempotent method and no body.
+func proxyRetryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+	}
+	return false
+}
+
+// pick to get the index of an available upstream not tried yet by the balance of the route, -1 if none.
+func (a *proxyHandler) pick(r *http.Request, tried []bool) int {
+	now := time.Now()
+	available := make([]bool, len(a.Upstream))
+	count := 0
+	for index, value := range a.Upstream {
+		if !tried[index] && value.available(now) {
+			available[index] = true
+			count++
+		}
+	}
+	if count == 0 {
+		return -1
+	}
+	switch a.Route.Balance {
+	case ProxyLeastConn:
+		best := -1
+		var bestActive int64
+		start := int(atomic.AddUint64(&a.next, 1) % uint64(len(a.Upstream))) //break tie in turn
+		for i := range a.Upstream {
+			index := (start + i) % len(a.Upstream)
+			if !available[index] {
+				continue
+			}
+			if active := atomic.LoadInt64(&a.Upstream[index].active); best < 0 || active < bestActive {
+				best, bestActive = index, active
+			}
+		}
+		return best
+	case ProxyHash:
+		if key := a.hashKey(r); key != "" {
+			hash := crc32.ChecksumIEEE([]byte(key))
+			start := sort.Search(len(a.Ring), func(i int) bool {
+				return a.Ring[i].Hash >= hash
+			})
+			for i := range a.Ring {
+				if node := a.Ring[(start+i)%len(a.Ring)]; available[node.Index] {
+					return node.Index
+				}
+			}
+		}
+	}
+	start := int(atomic.AddUint64(&a.next, 1) % uint64(len(a.Upstream)))
+	for i := range a.Upstream {
+		if index := (start + i) % len(a.Upstream); available[index] {
+			return index
+		}
+	}
+	return -1
+}
+
+func (a *proxyHandler) hashKey(r *http.Request) string {
+	if a.Route.HashHeader != "" {
+		return r.Header.Get(a.Route.HashHeader)
+	}
+	if cookie, err := r.Cookie(a.Route.HashCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// startHealthCheck to check every upstream now and then every IntervalSec until the handler is stopped.
+func (a *proxyHandler) startHealthCheck() {
+	check := a.Route.HealthCheck
+	if check.Path == "" {
+		return
+	}
+	if check.IntervalSec == 0 {
+		check.IntervalSec = 10
+	}
+	if check.TimeoutSec == 0 {
+		check.TimeoutSec = 2
+	}
+	client := &http.Client{Transport: a.transport, Timeout: time.Duration(check.TimeoutSec) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, value := range a.Upstream {
+		go func(upstream *proxyUpstream) {
+			ticker := time.NewTicker(time.Duration(check.IntervalSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				healthy := false
+				resp, err := client.Get(upstream.Url.String() + "/" + strings.TrimPrefix(check.Path, "/"))
+				if err == nil {
+					io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+					resp.Body.Close()
+					healthy = resp.StatusCode >= 200 && resp.StatusCode < 400
+				}
+				upstream.mutex.Lock()
+				if upstream.down == healthy {
+					log.Print("proxy upstream " + upstream.Url.String() + " of " + a.Route.Prefix + " healthy: " + strconv.FormatBool(healthy))
+				}
+				upstream.down = !healthy
+				upstream.mutex.Unlock()
+				select {
+				case <-a.stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(value)
+	}
+}
+
+func (u *proxyUpstream) available(now time.Time) bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.availableLocked(now)
+}
+
+func (u *proxyUpstream) availableLocked(now time.Time) bool {
+	return !u.down && !now.Before(u.ejectUntil)
+}
+
+// report to count the consecutive failures of the upstream and eject it for eject once maxFail is reached.
+func (u *proxyUpstream) report(failed bool, maxFail int, eject time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !failed {
+		u.fail = 0
+		return
+	}
+	u.fail++
+	if u.fail >= maxFail {
+		u.fail = 0
+		u.ejectUntil = time.Now().Add(eject)
+		log.Print("proxy upstream " + u.Url.String() + " ejected until " + u.ejectUntil.Format(time.RFC3339))
+	}
+}
+
+// Close is implementation method for the io.Closer interface.
+func (b *proxyBodyCloser) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.upstream.active, -1)
+	})
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,146 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	clientUtil "tiger/util/client"
+	"time"
+)
+
+// testUpstream is an upstream server answering status with its name, the forwarded path and X-Forwarded-Prefix.
+type testUpstream struct {
+	*httptest.Server
+	hit int32
+}
+
+func newTestUpstream(t *testing.T, name string, status int) *testUpstream {
+	u := &testUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&u.hit, 1)
+		w.WriteHeader(status)
+		io.WriteString(w, name+" "+r.URL.Path+" "+r.Header.Get("X-Forwarded-Prefix"))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *testUpstream) hits() int {
+	return int(atomic.LoadInt32(&u.hit))
+}
+
+func proxyUpstreamStat(upstream string) ProxyUpstreamStat {
+	for _, value := range ProxyStats() {
+		if value.Upstream == upstream {
+			return value
+		}
+	}
+	return ProxyUpstreamStat{}
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	a := newTestUpstream(t, "a", http.StatusOK)
+	b := newTestUpstream(t, "b", http.StatusOK)
+	handler := newTestHandler(t, newTestConfig(), func() {
+		if err := AddProxyRoute(ProxyRoute{Prefix: "/billing", Upstream: []string{a.URL, b.URL}, StripPrefix: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for i := 0; i < 4; i++ {
+		w := serveTest(handler, http.MethodGet, "/billing/invoice/1")
+		if w.Code != http.StatusOK || !strings.HasSuffix(w.Body.String(), " /invoice/1 /billing") {
+			t.Fatalf("GET /billing/invoice/1 = %d %q", w.Code, w.Body.String())
+		}
+	}
+	if a.hits() != 2 || b.hits() != 2 {
+		t.Errorf("round robin hits a=%d b=%d, want 2 each", a.hits(), b.hits())
+	}
+}
+
+func TestProxyRetryEject(t *testing.T) {
+	bad := newTestUpstream(t, "bad", http.StatusServiceUnavailable)
+	good := newTestUpstream(t, "good", http.StatusOK)
+	gone := newTestUpstream(t, "gone", http.StatusOK)
+	gone.Close() //connection refused
+	handler := newTestHandler(t, newTestConfig(), func() {
+		err := AddProxyRoute(ProxyRoute{Prefix: "/api/", Upstream: []string{bad.URL, gone.URL, good.URL}, MaxFail: 2, EjectSec: 60,
+			RequestInfo: clientUtil.RequestInfo{TimeoutSec: 2, RetryTimes: 3}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	for i := 0; i < 9; i++ {
+		if w := serveTest(handler, http.MethodGet, "/api/x"); w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "good ") {
+			t.Fatalf("GET /api/x %d = %d %q, want 200 from good", i, w.Code, w.Body.String())
+		}
+	}
+	if bad.hits() != 2 {
+		t.Errorf("bad upstream hit %d times, want 2 before its ejection", bad.hits())
+	}
+	for _, upstream := range []string{bad.URL, gone.URL} {
+		if stat := proxyUpstreamStat(upstream); stat.Healthy || stat.Ejected.IsZero() {
+			t.Errorf("upstream %s not ejected: %+v", upstream, stat)
+		}
+	}
+	if stat := proxyUpstreamStat(good.URL); !stat.Healthy {
+		t.Errorf("good upstream not healthy: %+v", stat)
+	}
+}
+
+func TestProxyNoRetry(t *testing.T) {
+	bad := newTestUpstream(t, "bad", http.StatusServiceUnavailable)
+	handler := newTestHandler(t, newTestConfig(), func() {
+		if err := AddProxyRoute(ProxyRoute{Prefix: "/api/", Upstream: []string{bad.URL}, MaxFail: 10, RequestInfo: clientUtil.RequestInfo{RetryTimes: 3}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/x", strings.NewReader("body")))
+	if w.Code != http.StatusServiceUnavailable || bad.hits() != 1 {
+		t.Errorf("POST with body = %d after %d tries, want the 503 of the upstream after 1 try", w.Code, bad.hits())
+	}
+	if w := serveTest(handler, http.MethodGet, "/api/x"); w.Code != http.StatusBadGateway || bad.hits() != 2 {
+		t.Errorf("GET without another upstream = %d after %d tries, want 502 after 1 try", w.Code, bad.hits()-1)
+	}
+}
+
+func TestProxyRemoveReplace(t *testing.T) {
+	a := newTestUpstream(t, "a", http.StatusOK)
+	b := newTestUpstream(t, "b", http.StatusOK)
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddProxyRoute(ProxyRoute{Prefix: "/a/", Upstream: []string{a.URL}, HealthCheck: ProxyHealthCheck{Path: "/health"}})
+		Group("/group").AddProxyRoute(ProxyRoute{Prefix: "/b/", Upstream: []string{b.URL}})
+	})
+	mutexProxy.Lock()
+	proxy := append([]*proxyHandler(nil), listProxyHandler...)
+	mutexProxy.Unlock()
+	if len(proxy) != 2 || proxy[0].Route.RequestInfo.TimeoutSec != 30 || proxy[0].transport.ResponseHeaderTimeout != 30*time.Second {
+		t.Fatalf("proxy routes %d, want 2 with the default timeout of 30 seconds", len(proxy))
+	}
+	if ReplaceHandler("/a/", proxy[0]) {
+		select {
+		case <-proxy[0].stop:
+			t.Error("health check stopped when the proxy route is replaced by itself")
+		default:
+		}
+	}
+	if !RemoveHandler("/a/") || !ReplaceHandler("/group/b/", echoPathHandler()) {
+		t.Fatal("proxy route not removed or replaced")
+	}
+	for _, value := range proxy {
+		select {
+		case <-value.stop:
+		default:
+			t.Errorf("health check of %s not stopped", value.Route.Prefix)
+		}
+	}
+	if stat := ProxyStats(); len(stat) != 0 {
+		t.Errorf("ProxyStats = %+v, want none once the proxy routes are gone", stat)
+	}
+	if w := serveTest(handler, http.MethodGet, "/group/b/x"); w.Body.String() != "GET /group/b/x" {
+		t.Errorf("GET /group/b/x = %q, want the replacement handler", w.Body.String())
+	}
+}
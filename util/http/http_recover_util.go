@@ -0,0 +1,42 @@
+package httpUtil
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	logUtil "tiger/util/log"
+)
+
+var panicCount int64
+
+// Recovery is the built-in Middleware recovering from a panic in any middleware, url mapping, chain or static file served by NewHandler or NewServeMux.
+// the panic value and stack trace are logged by logUtil with the request details, PanicCount is incremented, it is passed to the ErrorReporter and the custom 500 error page is served by Error if the header has not been written yet,
+// else the response cannot be fixed so the connection is aborted with http.ErrAbortHandler. a panic of http.ErrAbortHandler itself is passed on as it is.
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := WrapResponseWriter(w)
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			atomic.AddInt64(&panicCount, 1)
+			stack := debug.Stack()
+			logUtil.ErrorPrintf("panic serving %s %s host %s remote %s route %s: %v\n%s", r.Method, r.URL.String(), r.Host, r.RemoteAddr, RoutePattern(r), value, stack)
+			reportError(rw, r, http.StatusInternalServerError, panicError(value), true, stack)
+			if rw.WroteHeader() {
+				panic(http.ErrAbortHandler)
+			}
+			errorInternal(rw, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// PanicCount to get the number of panic recovered by Recovery since server startup.
+func PanicCount() int64 {
+	return atomic.LoadInt64(&panicCount)
+}
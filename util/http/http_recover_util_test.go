@@ -0,0 +1,69 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// servePanicTest to serve the request and get the value it panicked with, nil if it did not.
+func servePanicTest(handler http.Handler, target string) (w *httptest.ResponseRecorder, value interface{}) {
+	w = httptest.NewRecorder()
+	defer func() {
+		value = recover()
+	}()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w, nil
+}
+
+func TestRecovery(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("global") != "" {
+					panic("global middleware")
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		AddHandler("/panic/before", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Partial", "1")
+			panic("before write")
+		}))
+		AddHandler("/panic/after", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			panic("after write")
+		}))
+		AddHandler("/panic/abort", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		AddHandler("/panic/ok", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}))
+	})
+	count := PanicCount()
+	for _, target := range []string{"/panic/before", "/panic/ok?global=1"} {
+		w, value := servePanicTest(handler, target)
+		if value != nil || w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), http.StatusText(http.StatusInternalServerError)) {
+			t.Errorf("GET %s = %d %q panic %v, want the 500 error page", target, w.Code, w.Body.String(), value)
+		}
+	}
+	if PanicCount() != count+2 {
+		t.Errorf("PanicCount = %d, want %d", PanicCount(), count+2)
+	}
+	if w, value := servePanicTest(handler, "/panic/after"); value != http.ErrAbortHandler || w.Body.String() != "partial" {
+		t.Errorf("GET /panic/after = %q panic %v, want the connection aborted with http.ErrAbortHandler", w.Body.String(), value)
+	}
+	count = PanicCount()
+	if _, value := servePanicTest(handler, "/panic/abort"); value != http.ErrAbortHandler {
+		t.Errorf("GET /panic/abort panic %v, want http.ErrAbortHandler passed on", value)
+	}
+	if PanicCount() != count {
+		t.Error("http.ErrAbortHandler counted as a panic")
+	}
+	if w, value := servePanicTest(handler, "/panic/ok"); value != nil || w.Body.String() != "ok" {
+		t.Errorf("GET /panic/ok = %q panic %v, want ok", w.Body.String(), value)
+	}
+}
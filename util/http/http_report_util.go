@@ -0,0 +1,282 @@
+package httpUtil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrorReport is the error passed to every ErrorReporter with the request details.
+// the same error of the same url mapping has the same Fingerprint, Count is the number of times it happened so far.
+type ErrorReport struct {
+	Fingerprint string    `json:"fingerprint"`
+	Time        time.Time `json:"time"`
+	Status      int       `json:"status"`
+	Error       string    `json:"error"`
+	Panic       bool      `json:"panic,omitempty"`
+	Stack       string    `json:"stack,omitempty"`
+	Method      string    `json:"method"`
+	Url         string    `json:"url"`
+	Host        string    `json:"host"`
+	Remote      string    `json:"remote"`
+	Route       string    `json:"route,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	RequestId   string    `json:"requestId,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"firstSeen"`
+}
+
+// ErrorReporter is the interface for application to implement to send errors somewhere e.g a file, an error tracking service or a chat room.
+// Report is called on the goroutine serving the request so it must be quick or hand the report over to another goroutine.
+type ErrorReporter interface {
+	Report(report *ErrorReport)
+}
+
+// ErrorReportConfig is the config of the error reporting, set by SetErrorReportConfig.
+type ErrorReportConfig struct {
+	//lowest status code reported by Error and WriteError, default 500. panic are always reported
+	MinStatus int
+	//the same Fingerprint is passed to the reporters again at most once per Window with its Count, default 1 minute
+	Window time.Duration
+	//number of Fingerprint kept for ErrorStats, the least recently seen is dropped first, default 1000
+	MaxKeep int
+	//to get the user of the request e.g the login name set in the RequestState by an auth chain handler
+	User func(r *http.Request) string
+}
+
+// ErrorStat is the count of one Fingerprint shown on the /errors admin url.
+type ErrorStat struct {
+	Fingerprint string       `json:"fingerprint"`
+	Status      int          `json:"status"`
+	Error       string       `json:"error"`
+	Panic       bool         `json:"panic,omitempty"`
+	Method      string       `json:"method"`
+	Route       string       `json:"route"`
+	Count       int          `json:"count"`
+	FirstSeen   time.Time    `json:"firstSeen"`
+	LastSeen    time.Time    `json:"lastSeen"`
+	Last        *ErrorReport `json:"last"`
+
+	reported time.Time
+}
+
+// FileReporter is the built-in ErrorReporter appending every report as one json line to a file.
+type FileReporter struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+var mutexReport sync.Mutex
+var listErrorReporter []ErrorReporter
+var errorReportConfig = ErrorReportConfig{}
+var mapErrorStat map[string]*ErrorStat
+var digitRegEx = regexp.MustCompile(`[0-9]+`)
+
+var adminErrorsTemplate = template.Must(template.New("errors").Parse(`<!DOCTYPE html>
+<html><head><title>errors</title></head><body>
+<h1>recent errors</h1>
+<table border="1" cellpadding="4">
+<tr><th>last seen</th><th>first seen</th><th>count</th><th>status</th><th>route</th><th>error</th><th>last url</th><th>fingerprint</th></tr>
+{{range .Recent}}<tr><td>{{.LastSeen.Format "2006-01-02 15:04:05"}}</td><td>{{.FirstSeen.Format "2006-01-02 15:04:05"}}</td><td>{{.Count}}</td><td>{{.Status}}{{if .Panic}} panic{{end}}</td><td>{{.Method}} {{.Route}}</td><td>{{.Error}}</td><td>{{.Last.Url}}</td><td>{{.Fingerprint}}</td></tr>
+{{end}}</table>
+<h1>most frequent errors</h1>
+<table border="1" cellpadding="4">
+<tr><th>count</th><th>status</th><th>route</th><th>error</th><th>first seen</th><th>last seen</th><th>fingerprint</th></tr>
+{{range .Frequent}}<tr><td>{{.Count}}</td><td>{{.Status}}{{if .Panic}} panic{{end}}</td><td>{{.Method}} {{.Route}}</td><td>{{.Error}}</td><td>{{.FirstSeen.Format "2006-01-02 15:04:05"}}</td><td>{{.LastSeen.Format "2006-01-02 15:04:05"}}</td><td>{{.Fingerprint}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+// AddErrorReporter to add a reporter receiving the errors of Error, WriteError, WriteProblem and the panic recovered by Recovery.
+// 	Example
+// 	reporter, err := NewFileReporter("log/error.jsonl")
+// 	if err == nil {
+// 		AddErrorReporter(reporter)
+// 	}
+func AddErrorReporter(reporter ErrorReporter) {
+	mutexReport.Lock()
+	defer mutexReport.Unlock()
+	listErrorReporter = append(listErrorReporter, reporter)
+}
+
+// SetErrorReportConfig to set which error is reported, how often and how the user is found. zero value fields use the default.
+// 	Example SetErrorReportConfig(ErrorReportConfig{User: func(r *http.Request) string { return UserKey.GetOr(r, "") }})
+func SetErrorReportConfig(config ErrorReportConfig) {
+	mutexReport.Lock()
+	defer mutexReport.Unlock()
+	errorReportConfig = config
+}
+
+// ErrorStats to get the counted errors, the most recently seen first. errors are counted even without ErrorReporter.
+func ErrorStats() []ErrorStat {
+	mutexReport.Lock()
+	defer mutexReport.Unlock()
+	var stat []ErrorStat
+	for _, value := range mapErrorStat {
+		stat = append(stat, *value)
+	}
+	sort.Slice(stat, func(i, j int) bool {
+		return stat[i].LastSeen.After(stat[j].LastSeen)
+	})
+	return stat
+}
+
+// ErrorStatsHandler to get the handler of the /errors admin url listing the recent and the most frequent errors. ?format=json or ?format=html else decided by the Accept header
+func ErrorStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recent := ErrorStats()
+		frequent := append([]ErrorStat(nil), recent...)
+		sort.SliceStable(frequent, func(i, j int) bool {
+			return frequent[i].Count > frequent[j].Count
+		})
+		if limit := 50; len(recent) > limit {
+			recent, frequent = recent[:limit], frequent[:limit]
+		}
+		writeAdmin(w, r, adminErrorsTemplate, map[string][]ErrorStat{"Recent": recent, "Frequent": frequent})
+	})
+}
+
+// reportError to count the error and pass it to the reporters if its status is reported.
+func reportError(w http.ResponseWriter, r *http.Request, status int, err error, panicked bool, stack []byte) {
+	mutexReport.Lock()
+	config := errorReportConfig
+	mutexReport.Unlock()
+	if config.MinStatus == 0 {
+		config.MinStatus = http.StatusInternalServerError
+	}
+	if !panicked && status < config.MinStatus {
+		return
+	}
+	if config.Window == 0 {
+		config.Window = time.Minute
+	}
+	if config.MaxKeep == 0 {
+		config.MaxKeep = 1000
+	}
+	report := &ErrorReport{Time: time.Now(), Status: status, Error: err.Error(), Panic: panicked, Stack: string(stack), Method: r.Method, Url: r.URL.String(),
+		Host: r.Host, Remote: r.RemoteAddr, Route: RoutePattern(r), UserAgent: r.UserAgent(), RequestId: requestId(w, r)}
+	if config.User != nil {
+		report.User = config.User(r)
+	}
+	report.Fingerprint = fingerprint(report, r.URL.Path)
+
+	mutexReport.Lock()
+	if mapErrorStat == nil {
+		mapErrorStat = make(map[string]*ErrorStat)
+	}
+	stat, found := mapErrorStat[report.Fingerprint]
+	if !found {
+		if len(mapErrorStat) >= config.MaxKeep {
+			dropErrorStat()
+		}
+		route := report.Route
+		if route == "" {
+			route = r.URL.Path
+		}
+		stat = &ErrorStat{Fingerprint: report.Fingerprint, Status: status, Error: report.Error, Panic: panicked, Method: r.Method, Route: route, FirstSeen: report.Time}
+		mapErrorStat[report.Fingerprint] = stat
+	}
+	report.Count, report.FirstSeen = stat.Count+1, stat.FirstSeen
+	send := report.Time.Sub(stat.reported) >= config.Window
+	reporter := listErrorReporter
+	if send && stack == nil && len(reporter) > 0 { //only the report passed to the reporters need where the error was written
+		report.Stack = string(debug.Stack())
+	}
+	//report is not changed once stored as ErrorStats and the /errors admin url read it
+	stat.Count++
+	stat.LastSeen = report.Time
+	stat.Last = report
+	if send {
+		stat.reported = report.Time
+	}
+	mutexReport.Unlock()
+
+	if !send {
+		return
+	}
+	for _, value := range reporter {
+		value.Report(report)
+	}
+}
+
+// dropErrorStat to remove the least recently seen ErrorStat. must be called with mutexReport locked.
+func dropErrorStat() {
+	var oldest *ErrorStat
+	for _, value := range mapErrorStat {
+		if oldest == nil || value.LastSeen.Before(oldest.LastSeen) {
+			oldest = value
+		}
+	}
+	if oldest != nil {
+		delete(mapErrorStat, oldest.Fingerprint)
+	}
+}
+
+// fingerprint to hash the status, method, url mapping (or url path) and the error text with numbers removed so e.g "id 12 not found" and "id 13 not found" are the same.
+// the url path is used without the query string and with numbers removed too when no url mapping matched e.g a 404.
+func fingerprint(report *ErrorReport, urlPath string) string {
+	route := report.Route
+	if route == "" {
+		route = digitRegEx.ReplaceAllString(urlPath, "0")
+	}
+	sum := sha256.Sum256([]byte(strconv.Itoa(report.Status) + "|" + strconv.FormatBool(report.Panic) + "|" + report.Method + "|" + route + "|" + digitRegEx.ReplaceAllString(report.Error, "0")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewFileReporter to get a FileReporter appending to the file, created if it does not exist.
+func NewFileReporter(path string) (*FileReporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileReporter{file: file}, nil
+}
+
+// Report is implementation method for the ErrorReporter interface.
+func (f *FileReporter) Report(report *ErrorReport) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return
+	}
+	if _, err := f.file.Write(append(b, '\n')); err != nil {
+		log.Print("error write error report " + f.file.Name() + ": " + err.Error())
+	}
+}
+
+// Close to close the file. reports after Close are dropped.
+func (f *FileReporter) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return errors.New("file reporter already closed")
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// panicError to get the error of a recovered panic value.
+func panicError(value interface{}) error {
+	if err, ok := value.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", value)
+}
@@ -0,0 +1,188 @@
+package httpUtil
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordReporter struct {
+	mutex  sync.Mutex
+	report []*ErrorReport
+}
+
+func (rr *recordReporter) Report(report *ErrorReport) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.report = append(rr.report, report)
+}
+
+func TestReportError(t *testing.T) {
+	reporter := &recordReporter{}
+	file := filepath.Join(t.TempDir(), "error.jsonl")
+	fileReporter, err := NewFileReporter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddErrorReporter(reporter)
+		AddErrorReporter(fileReporter)
+		SetErrorReportConfig(ErrorReportConfig{User: func(r *http.Request) string { return r.Header.Get("X-User") }})
+		AddHandler("/report/fail", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "db down", http.StatusServiceUnavailable)
+		}))
+		AddHandler("/report/bad", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "bad", http.StatusBadRequest)
+		}))
+		AddHandler("/report/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+	})
+	serveTest(handler, http.MethodGet, "/report/bad")
+	serveTest(handler, http.MethodGet, "/report/missing")
+	serveTest(handler, http.MethodGet, "/report/fail")
+	serveTest(handler, http.MethodGet, "/report/panic")
+	if len(reporter.report) != 2 {
+		t.Fatalf("%d reports, want 2: the 503 and the panic below MinStatus 500 are not reported", len(reporter.report))
+	}
+	if report := reporter.report[0]; report.Status != http.StatusServiceUnavailable || report.Error != "db down" || report.Route != "/report/fail" || report.Count != 1 {
+		t.Errorf("report = %+v, want the 503 of /report/fail", report)
+	}
+	if report := reporter.report[1]; !report.Panic || report.Error != "panic: boom" || report.Stack == "" {
+		t.Errorf("report = %+v, want the panic with its stack", report)
+	}
+	if stat := ErrorStats(); len(stat) != 2 || stat[0].Route != "/report/panic" {
+		t.Errorf("ErrorStats = %+v, want the panic then the 503", stat)
+	}
+
+	if err := fileReporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fileReporter.Close() == nil {
+		t.Error("second Close of the FileReporter did not fail")
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var report ErrorReport
+	if len(line) != 2 || json.Unmarshal([]byte(line[0]), &report) != nil || report.Fingerprint != reporter.report[0].Fingerprint {
+		t.Errorf("FileReporter wrote %q, want one json line per report", b)
+	}
+}
+
+func TestErrorStatsHandler(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		SetErrorReportConfig(ErrorReportConfig{MinStatus: http.StatusNotFound})
+	})
+	for _, target := range []string{"/stats/a", "/stats/b", "/stats/b"} {
+		serveTest(handler, http.MethodGet, target)
+	}
+	w := serveTest(ErrorStatsHandler(), http.MethodGet, "/errors?format=json")
+	var stat map[string][]ErrorStat
+	if err := json.Unmarshal(w.Body.Bytes(), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if len(stat["Frequent"]) != 2 || stat["Frequent"][0].Route != "/stats/b" || stat["Frequent"][0].Count != 2 {
+		t.Errorf("/errors Frequent = %+v, want /stats/b counted twice first", stat["Frequent"])
+	}
+	if w := serveTest(ErrorStatsHandler(), http.MethodGet, "/errors?format=html"); !strings.Contains(w.Body.String(), "<td>/stats/b</td>") {
+		t.Errorf("/errors html does not list /stats/b: %s", w.Body.String())
+	}
+}
+
+func TestReportErrorWindow(t *testing.T) {
+	reporter := &recordReporter{}
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddErrorReporter(reporter)
+		AddHandlerPlaceholder("/fail/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "id "+PathParam(r, "id")+" failed", http.StatusInternalServerError)
+		}))
+		AddHandler("/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+	})
+	serveTest(handler, http.MethodGet, "/fail/1")
+	if stat := ErrorStats(); len(stat) != 1 || stat[0].Last.Stack == "" {
+		t.Error("last report of the ErrorStat passed to the reporters without its stack")
+	}
+	for _, target := range []string{"/fail/2?x=1", "/fail/3"} {
+		serveTest(handler, http.MethodGet, target)
+	}
+	serveTest(handler, http.MethodGet, "/panic")
+	if len(reporter.report) != 2 {
+		t.Fatalf("%d reports, want 2: the first error of the window and the panic", len(reporter.report))
+	}
+	if reporter.report[0].Stack == "" || reporter.report[1].Stack == "" || !reporter.report[1].Panic {
+		t.Error("report passed to the reporters without its stack")
+	}
+	var count int
+	for _, value := range ErrorStats() {
+		if value.Route == "/fail/{id}" {
+			count = value.Count
+			if value.Last.Stack != "" {
+				t.Error("stack captured for an error not passed to the reporters")
+			}
+		}
+	}
+	if count != 3 {
+		t.Errorf("ErrorStat count of /fail/{id} = %d, want 3", count)
+	}
+}
+
+func TestReportErrorFingerprintPath(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {})
+	for _, target := range []string{"/missing/1?page=2", "/missing/22?page=3", "/missing/333"} {
+		serveTest(handler, http.MethodGet, target)
+	}
+	serveTest(handler, http.MethodGet, "/other/1")
+	SetErrorReportConfig(ErrorReportConfig{MinStatus: http.StatusNotFound})
+	for _, target := range []string{"/missing/1?page=2", "/missing/22?page=3", "/missing/333", "/other/1"} {
+		serveTest(handler, http.MethodGet, target)
+	}
+	stat := ErrorStats()
+	if len(stat) != 2 {
+		t.Fatalf("%d ErrorStat, want 2: /missing/0 and /other/0", len(stat))
+	}
+	for _, value := range stat {
+		if want := map[string]int{"/missing/1": 3, "/other/1": 1}[value.Route]; value.Count != want {
+			t.Errorf("ErrorStat %s count = %d, want %d", value.Route, value.Count, want)
+		}
+	}
+}
+
+func TestReportErrorConcurrent(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddErrorReporter(&recordReporter{})
+		SetErrorReportConfig(ErrorReportConfig{Window: time.Nanosecond})
+		AddHandler("/race", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, "db down", http.StatusInternalServerError)
+		}))
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				serveTest(handler, http.MethodGet, "/race")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				serveTest(ErrorStatsHandler(), http.MethodGet, "/errors?format=json")
+			}
+		}()
+	}
+	wg.Wait()
+	if stat := ErrorStats(); len(stat) != 1 || stat[0].Count != 160 {
+		t.Errorf("ErrorStats = %+v, want /race counted 160 times", stat)
+	}
+}
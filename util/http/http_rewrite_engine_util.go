@@ -0,0 +1,407 @@
+package httpUtil
+
+import (
+	"container/list"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rewriteEngine is the compiled rewrite rules of one host pattern. it is never changed once published, adding a rule publish a new one.
+// the index is built on first use: exact source url, a trie of the path param source url, the first path segment of the glob and anchored regular expression source url
+// the first character of the glob source url not starting with / and the start of a path segment the other regular expression need.
+// a source url none of them can narrow down is tried for every url path.
+type rewriteEngine struct {
+	Rule []*rewriteRule
+
+	once       sync.Once
+	exact      map[string][]int
+	trie       *rewriteTrie
+	bucket     map[string][]int
+	lead       map[byte][]int
+	prefix     map[string][]int
+	prefixLen  []int
+	scan       []int
+	chainStart []int
+	chainEnd   []int
+}
+
+type rewriteTrie struct {
+	Literal  map[string]*rewriteTrie
+	Param    *rewriteTrie
+	Rule     []int
+	CatchAll []int
+}
+
+type rewriteCacheEntry struct {
+	Key    string
+	Result rewriteResult
+}
+
+// rewriteCache is the LRU of recent rewrite results keyed by host, url path and query string.
+type rewriteCache struct {
+	mutex    sync.Mutex
+	capacity int
+	list     *list.List
+	item     map[string]*list.Element
+}
+
+var rewriteResultCache = newRewriteCache(1024)
+
+// compileRewriteSource to compile the source url of the rule once, every way it can match as before compiling: glob pattern, path param token and regular expression.
+// e.g /old/.* is a glob and a regular expression, /about is also a regular expression matching /x/about/y.
+// a regular expression anchored by ^ with a literal first path segment e.g ^/search/(.*)$ is indexed by that segment, any other with a literal prefix containing / by the path segment it start.
+func compileRewriteSource(rule *rewriteRule) {
+	source := rule.Rule.SourceUrl
+	if index := strings.IndexAny(source, `*?[\`); index >= 0 { //without them a glob is the same url path
+		if _, err := filepath.Match(source, ""); err == nil {
+			rule.Glob = true
+			rule.GlobBucket = firstSegment(source[:index], false)
+			if !strings.HasPrefix(source, "/") {
+				rule.GlobLead = source[:index]
+			}
+		}
+	}
+	if token, err := parsePathParamToken(source); err == nil {
+		for _, value := range token {
+			if value.IsParam {
+				rule.Token = token
+				break
+			}
+		}
+	}
+	if re, err := regexp.Compile(source); err == nil {
+		rule.Re = re
+		rule.ReLiteral, _ = re.LiteralPrefix()
+		if parsed, err := syntax.Parse(source, syntax.Perl); err == nil && parsed.Op == syntax.OpConcat && len(parsed.Sub) > 0 && parsed.Sub[0].Op == syntax.OpBeginText {
+			rule.ReBucket = firstSegment(rule.ReLiteral, false)
+		}
+		if index := strings.Index(rule.ReLiteral, "/"); index >= 0 {
+			rule.RePrefix = rule.ReLiteral[index+1:]
+			if end := strings.Index(rule.RePrefix, "/"); end >= 0 {
+				rule.RePrefix = rule.RePrefix[:end]
+			}
+		}
+	}
+}
+
+// firstSegment to get the first path segment of path. if complete is false the segment must be followed by / to be sure it is whole, else empty string.
+func firstSegment(path string, complete bool) string {
+	if !strings.HasPrefix(path, "/") {
+		return ""
+	}
+	path = path[1:]
+	if index := strings.Index(path, "/"); index >= 0 {
+		return path[:index]
+	}
+	if complete {
+		return path
+	}
+	return ""
+}
+
+// match to check the url path against the source url in the same order as before compiling: same url, glob, path param then regular expression.
+func (rule *rewriteRule) match(path string, token []string) (bool, []rewriteCapture) {
+	if rule.Rule.SourceUrl == path {
+		return true, nil
+	}
+	if rule.Glob {
+		if found, _ := filepath.Match(rule.Rule.SourceUrl, path); found {
+			return true, nil
+		}
+	}
+	if rule.Token != nil {
+		if pathParam, found := matchPathParamToken(rule.Token, token, false); found && len(pathParam) > 0 {
+			var capture []rewriteCapture
+			for _, value := range rule.Token {
+				if value.IsParam {
+					capture = append(capture, rewriteCapture{Placeholder: value.Raw, Value: pathParam[value.Name]})
+				}
+			}
+			return true, capture
+		}
+	}
+	if rule.Re != nil && strings.Contains(path, rule.ReLiteral) {
+		if matched := rule.Re.FindStringSubmatch(path); matched != nil {
+			var capture []rewriteCapture
+			for index := len(matched) - 1; index >= 0; index-- { //$10 before $1
+				capture = append(capture, rewriteCapture{Placeholder: "$" + strconv.Itoa(index), Value: matched[index]})
+			}
+			return true, capture
+		}
+	}
+	return false, nil
+}
+
+// with to get a new engine with rule added at the end or replacing the rule at index replace if not -1.
+func (e *rewriteEngine) with(rule *rewriteRule, replace int) *rewriteEngine {
+	var current []*rewriteRule
+	if e != nil {
+		current = e.Rule
+	}
+	next := append(make([]*rewriteRule, 0, len(current)+1), current...)
+	if replace >= 0 {
+		next[replace] = rule
+	} else {
+		next = append(next, rule)
+	}
+	return &rewriteEngine{Rule: next}
+}
+
+func (e *rewriteEngine) build() {
+	e.once.Do(func() {
+		e.exact = make(map[string][]int)
+		e.trie = &rewriteTrie{}
+		e.bucket = make(map[string][]int)
+		e.lead = make(map[byte][]int)
+		e.prefix = make(map[string][]int)
+		e.chainStart = make([]int, len(e.Rule))
+		e.chainEnd = make([]int, len(e.Rule))
+		for index, rule := range e.Rule {
+			e.exact[rule.Rule.SourceUrl] = append(e.exact[rule.Rule.SourceUrl], index)
+			if rule.Token != nil {
+				e.trie.add(rule.Token, index)
+			}
+			scan := false
+			if rule.Glob {
+				if rule.GlobBucket != "" {
+					e.bucket[rule.GlobBucket] = append(e.bucket[rule.GlobBucket], index)
+				} else if rule.GlobLead != "" {
+					e.lead[rule.GlobLead[0]] = append(e.lead[rule.GlobLead[0]], index)
+				} else {
+					scan = true
+				}
+			}
+			if rule.Re != nil {
+				if rule.ReBucket != "" {
+					e.bucket[rule.ReBucket] = append(e.bucket[rule.ReBucket], index)
+				} else if rule.RePrefix != "" {
+					if e.prefix[rule.RePrefix] == nil {
+						e.addPrefixLen(len(rule.RePrefix))
+					}
+					e.prefix[rule.RePrefix] = append(e.prefix[rule.RePrefix], index)
+				} else {
+					scan = true
+				}
+			}
+			if scan {
+				e.scan = append(e.scan, index)
+			}
+			e.chainStart[index] = index
+			if index > 0 && e.Rule[index-1].Rule.Chain {
+				e.chainStart[index] = e.chainStart[index-1]
+			}
+		}
+		for index := len(e.Rule) - 1; index >= 0; index-- {
+			e.chainEnd[index] = index
+			if e.Rule[index].Rule.Chain && index+1 < len(e.Rule) {
+				e.chainEnd[index] = e.chainEnd[index+1]
+			}
+		}
+	})
+}
+
+// addPrefixLen to add the length of a regular expression prefix once, lookup try every length on every path segment
+func (e *rewriteEngine) addPrefixLen(length int) {
+	for _, value := range e.prefixLen {
+		if value == length {
+			return
+		}
+	}
+	e.prefixLen = append(e.prefixLen, length)
+}
+
+func (t *rewriteTrie) add(token []*pathParamToken, index int) {
+	node := t
+	for _, value := range token {
+		if value.CatchAll {
+			node.CatchAll = append(node.CatchAll, index)
+			return
+		}
+		if value.IsParam {
+			if node.Param == nil {
+				node.Param = &rewriteTrie{}
+			}
+			node = node.Param
+			continue
+		}
+		if node.Literal == nil {
+			node.Literal = make(map[string]*rewriteTrie)
+		}
+		if node.Literal[value.Raw] == nil {
+			node.Literal[value.Raw] = &rewriteTrie{}
+		}
+		node = node.Literal[value.Raw]
+	}
+	node.Rule = append(node.Rule, index)
+}
+
+func (t *rewriteTrie) collect(token []string, candidate []int) []int {
+	candidate = append(candidate, t.CatchAll...)
+	if len(token) == 0 {
+		return append(candidate, t.Rule...)
+	}
+	if child := t.Literal[token[0]]; child != nil {
+		candidate = child.collect(token[1:], candidate)
+	}
+	if t.Param != nil {
+		candidate = t.Param.collect(token[1:], candidate)
+	}
+	return candidate
+}
+
+// lookup to get the sorted index of the rules that may match the url path.
+func (e *rewriteEngine) lookup(path string, token []string) []int {
+	e.build()
+	candidate := append([]int(nil), e.exact[path]...)
+	candidate = e.trie.collect(token, candidate)
+	candidate = append(candidate, e.bucket[firstSegment(path, true)]...)
+	if len(path) > 0 {
+		candidate = append(candidate, e.lead[path[0]]...)
+	}
+	if len(e.prefixLen) > 0 {
+		for rest := path; ; {
+			index := strings.Index(rest, "/")
+			if index < 0 {
+				break
+			}
+			rest = rest[index+1:]
+			segment := rest
+			if end := strings.Index(segment, "/"); end >= 0 {
+				segment = segment[:end]
+			}
+			for _, length := range e.prefixLen {
+				if length <= len(segment) {
+					candidate = append(candidate, e.prefix[segment[:length]]...)
+				}
+			}
+		}
+	}
+	candidate = append(candidate, e.scan...)
+	sort.Ints(candidate)
+	unique := candidate[:0]
+	for index, value := range candidate {
+		if index == 0 || value != candidate[index-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// apply to apply the rules in order to the result. return true if a rule stop the rule list and false if the result depend on more than the host, url path and query string.
+func (e *rewriteEngine) apply(r *http.Request, result *rewriteResult) (stop bool, cacheable bool) {
+	cacheable = true
+	if e == nil || len(e.Rule) == 0 {
+		return false, cacheable
+	}
+	token := splitBySlashToken(result.Url)
+	candidate := e.lookup(result.Url, token)
+	matched := make(map[int]bool)
+	for position := 0; ; {
+		next := sort.SearchInts(candidate, position)
+		if next == len(candidate) {
+			return false, cacheable
+		}
+		index := candidate[next]
+		value := e.Rule[index]
+		position = index + 1
+		if start := e.chainStart[index]; start < index { //tried only if every rule before it in the chain matched
+			chained := true
+			for k := start; k < index && chained; k++ {
+				chained = matched[k]
+			}
+			if !chained {
+				continue
+			}
+		}
+		ok, capture := value.match(result.Url, token)
+		if ok {
+			ok, capture = matchRewriteQuery(result.RawQuery, value.SourceQuery, capture)
+		}
+		if ok {
+			cacheable = cacheable && !value.RequestDependent
+			ok = matchRewriteCondition(r, value.Rule.Condition)
+		}
+		if !ok {
+			if value.Rule.Chain {
+				position = e.chainEnd[index] + 1
+			}
+			continue
+		}
+		matched[index] = true
+		path, rawQuery := expandRewriteTarget(&value.Rule, capture, result.RawQuery)
+		result.Step = append(result.Step, RewriteStep{Host: value.Host, SourceUrl: value.Rule.SourceUrl, TargetUrl: value.Rule.TargetUrl,
+			From: joinQuery(result.Url, result.RawQuery), To: joinQuery(path, rawQuery), Redirect: value.Rule.Redirect})
+		changed := path != result.Url
+		result.Url, result.RawQuery = path, rawQuery
+		if value.Rule.Redirect != 0 {
+			result.Redirect = value.Rule.Redirect
+			return true, cacheable
+		}
+		if value.Rule.Last {
+			return true, cacheable
+		}
+		if changed {
+			token = splitBySlashToken(result.Url)
+			candidate = e.lookup(result.Url, token)
+		}
+	}
+}
+
+func newRewriteCache(capacity int) *rewriteCache {
+	return &rewriteCache{capacity: capacity, list: list.New(), item: make(map[string]*list.Element)}
+}
+
+func (c *rewriteCache) get(key string) (rewriteResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, found := c.item[key]; found {
+		c.list.MoveToFront(element)
+		return element.Value.(*rewriteCacheEntry).Result, true
+	}
+	return rewriteResult{}, false
+}
+
+func (c *rewriteCache) put(key string, result rewriteResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.capacity <= 0 {
+		return
+	}
+	if element, found := c.item[key]; found {
+		element.Value.(*rewriteCacheEntry).Result = result
+		c.list.MoveToFront(element)
+		return
+	}
+	c.item[key] = c.list.PushFront(&rewriteCacheEntry{Key: key, Result: result})
+	for c.list.Len() > c.capacity {
+		oldest := c.list.Back()
+		c.list.Remove(oldest)
+		delete(c.item, oldest.Value.(*rewriteCacheEntry).Key)
+	}
+}
+
+// reset to remove every result and set the capacity, negative keep the capacity.
+func (c *rewriteCache) reset(capacity int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if capacity >= 0 {
+		c.capacity = capacity
+	}
+	c.list.Init()
+	c.item = make(map[string]*list.Element)
+}
+
+// SetRewriteCacheSize to set the number of recent rewrite results kept, default 1024. 0 disable the cache.
+// results depending on a RewriteCondition on method, header or cookie are never kept. run go test -bench BenchmarkRewrite ./util/http/ to measure.
+func SetRewriteCacheSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	rewriteResultCache.reset(size)
+}
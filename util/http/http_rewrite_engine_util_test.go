@@ -0,0 +1,170 @@
+package httpUtil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// legacyMatchRewriteUrlSource is the matching of a rewrite source url before the rules were compiled, kept as the reference of the engine.
+func legacyMatchRewriteUrlSource(incomingSourceUrl, mapSourceUrl string) (bool, []rewriteCapture) {
+	if mapSourceUrl == incomingSourceUrl { //direct match
+		return true, nil
+	}
+	if found, _ := filepath.Match(mapSourceUrl, incomingSourceUrl); found { //filepath match
+		return true, nil
+	}
+
+	actualToken := splitBySlashToken(incomingSourceUrl)
+	if mapSourceToken, err := parsePathParamToken(mapSourceUrl); err == nil { //path param match
+		if pathParam, found := matchPathParamToken(mapSourceToken, actualToken, false); found && len(pathParam) > 0 {
+			var capture []rewriteCapture
+			for _, token := range mapSourceToken {
+				if token.IsParam {
+					capture = append(capture, rewriteCapture{Placeholder: token.Raw, Value: pathParam[token.Name]})
+				}
+			}
+			return true, capture
+		}
+	}
+
+	if srcRe, err := regexp.Compile(mapSourceUrl); err == nil {
+		if matched := srcRe.FindStringSubmatch(incomingSourceUrl); matched != nil {
+			var capture []rewriteCapture
+			for index := len(matched) - 1; index >= 0; index-- { //$10 before $1
+				capture = append(capture, rewriteCapture{Placeholder: "$" + strconv.Itoa(index), Value: matched[index]})
+			}
+			return true, capture
+		}
+	}
+
+	return false, nil
+}
+
+var rewriteEquivalenceSource = []string{
+	//exact, also a regular expression matching anywhere
+	"/about", "/old-about.html", "/exact1/page",
+	//glob
+	"/old/*", "/img/*.png", "/a?c", "/[ab]x", `/x/y\z`,
+	//regular expression with only glob characters or dot
+	"/old/.*", "/a.b", "/v[0-9]/list", "/dup?",
+	//regular expression
+	"^/search/(.*)$", `(.*)\.php$`, "/blog/([0-9]+)", "(?i)/CASE", "^/shop", "^/", "/(x",
+	//path param
+	"/user/{id}", "/user/{id:int}/x", "/files/{*path}", "/:slug/view", "/p/{id}/(.*)",
+}
+
+var rewriteEquivalencePath = []string{
+	"/", "", "/about", "/x/about/y", "/aboutus", "/old-about.html", "/old-aboutxhtml", "/exact1/page", "/q/exact1/pages",
+	"/old/12", "/old/", "/old", "/img/a.png", "/img/a/b.png", "/abc", "/ax", "/bx", "/cx", `/x/y\z`, "/x/yz",
+	"/a.b", "/axb", "/z/a-b/c", "/v1/list", "/api/v2/list", "/du", "/dup", "/search/go", "/x/search/go",
+	"/index.php", "/a/b.php", "/blog/12", "/a/blog/12/x", "/case", "/CASE", "/shop", "/shopping/cart", "/(x",
+	"/user/5", "/user/abc", "/user/5/x", "/user/abc/x", "/files/a/b/c", "/foo/view", "/p/1/rest", "/p/{id}/rest",
+}
+
+func compileTestRule(t testing.TB, source string) *rewriteRule {
+	rule, err := compileRewriteRule("", RewriteRule{SourceUrl: source, TargetUrl: "/target", Last: true})
+	if err != nil {
+		t.Fatalf("compile %s: %v", source, err)
+	}
+	return rule
+}
+
+func TestRewriteEngineMatchLegacy(t *testing.T) {
+	for _, source := range rewriteEquivalenceSource {
+		rule := compileTestRule(t, source)
+		engine := (*rewriteEngine)(nil).with(rule, -1)
+		for _, path := range rewriteEquivalencePath {
+			wantOk, wantCapture := legacyMatchRewriteUrlSource(path, source)
+			token := splitBySlashToken(path)
+			gotOk, gotCapture := rule.match(path, token)
+			if gotOk != wantOk || !reflect.DeepEqual(gotCapture, wantCapture) {
+				t.Errorf("source %q path %q: match %v %v, legacy %v %v", source, path, gotOk, gotCapture, wantOk, wantCapture)
+			}
+			if wantOk && len(engine.lookup(path, token)) == 0 {
+				t.Errorf("source %q path %q: matching rule not found by the index", source, path)
+			}
+		}
+	}
+}
+
+func TestRewriteEngineFirstMatchLegacy(t *testing.T) {
+	var engine *rewriteEngine
+	for _, source := range rewriteEquivalenceSource {
+		engine = engine.with(compileTestRule(t, source), -1)
+	}
+	for _, path := range rewriteEquivalencePath {
+		want := -1
+		for index, source := range rewriteEquivalenceSource {
+			if ok, _ := legacyMatchRewriteUrlSource(path, source); ok {
+				want = index
+				break
+			}
+		}
+		got := -1
+		token := splitBySlashToken(path)
+		for _, index := range engine.lookup(path, token) {
+			if ok, _ := engine.Rule[index].match(path, token); ok {
+				got = index
+				break
+			}
+		}
+		if got != want {
+			t.Errorf("path %q: first matching rule %d, legacy %d", path, got, want)
+		}
+	}
+}
+
+func TestRewriteUrlRegexWithGlobCharacter(t *testing.T) {
+	Reset()
+	defer Reset()
+	AddRewriteUrl("/old/.*", "/new")
+	AddRewriteUrl("/a.b", "/dot")
+	for path, want := range map[string]string{"/old/12": "/new", "/axb": "/dot", "/a.b": "/dot", "/other": "/other"} {
+		if got := GetRewriteUrlTarget(path); got != want {
+			t.Errorf("GetRewriteUrlTarget(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// BenchmarkRewrite measure the rewrite of one url path with rules of every kind: exact, path param, glob and regular expression.
+func BenchmarkRewrite(b *testing.B) {
+	defer Reset()
+	defer SetRewriteCacheSize(1024)
+	for _, ruleCount := range []int{10, 100, 1000, 10000} {
+		for _, cache := range []bool{false, true} {
+			b.Run(fmt.Sprintf("rules=%d/cache=%t", ruleCount, cache), func(b *testing.B) {
+				Reset()
+				size := 0
+				if cache {
+					size = 1024
+				}
+				SetRewriteCacheSize(size)
+				for index := 0; index < ruleCount; index++ {
+					switch index % 4 {
+					case 0:
+						AddRewriteUrl(fmt.Sprintf("/exact%d/page", index), fmt.Sprintf("/target%d", index))
+					case 1:
+						AddRewriteUrl(fmt.Sprintf("/param%d/{id}", index), fmt.Sprintf("/target%d/{id}", index))
+					case 2:
+						AddRewriteUrl(fmt.Sprintf("/glob%d/*.html", index), fmt.Sprintf("/target%d", index))
+					default:
+						AddRewriteUrl(fmt.Sprintf("^/regex%d/(.*)$", index), fmt.Sprintf("/target%d/$1", index))
+					}
+				}
+				last := ruleCount - 1
+				path := []string{fmt.Sprintf("/param%d/12", last-last%4+1), fmt.Sprintf("/regex%d/a/b", last-last%4+3), "/nomatch/at/all"}
+				r := &http.Request{Method: http.MethodGet, Host: "example.com", URL: &url.URL{}, Header: make(http.Header)}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					traceRewriteUrl(r, path[i%len(path)])
+				}
+			})
+		}
+	}
+}
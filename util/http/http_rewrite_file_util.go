@@ -0,0 +1,418 @@
+package httpUtil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RewriteFileError is one invalid rule of a rewrite file with its line number.
+type RewriteFileError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// rewriteFileRule is one rule of a json rewrite file, Host is a host pattern like Host(...), empty for every host.
+type rewriteFileRule struct {
+	Host string
+	RewriteRule
+}
+
+type rewriteFileStat struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// rewriteFilePoll is the state of the poll of the rewrite file: the stat of the loaded file and of a change seen by the previous poll.
+type rewriteFilePoll struct {
+	Path    string
+	Loaded  rewriteFileStat
+	Pending rewriteFileStat
+}
+
+var mapFileRewriteEngine map[string]*rewriteEngine //host pattern to the rules of the rewrite file, "" for the default host
+var onceRewriteFile sync.Once
+var stopRewriteFile chan struct{}
+var mutexRewriteFile sync.Mutex //serialize the load of the rewrite file
+
+// RewriteFilePollInterval is how often the rewrite file of the json attribute UrlRewriteFile in config.json is checked for change.
+var RewriteFilePollInterval = 2 * time.Second
+
+// Error is implementation method for the error interface.
+func (e *RewriteFileError) Error() string {
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+// LoadRewriteFile to replace the rules of the rewrite file by the rules of the file at path. a file ending with .json is a json array of RewriteRule with an optional Host, else it is a text file.
+// every rule is validated first, if any is invalid the current rules are kept and every RewriteFileError is returned joined by errors.Join.
+// the rules of the rewrite file are tried before the rules added by code of the same host pattern. calling LoadRewriteFile again replace all of them at once.
+//
+// a text file has one rule per line, blank lines and lines starting with # are ignored.
+// 	host <host pattern>                       the next rules are only for the host pattern, host alone to go back to every host
+// 	cond [!]<kind> [name] [pattern]           a RewriteCondition of the next rule, name only for header, query and cookie, pattern is the rest of the line
+// 	<SourceUrl> <TargetUrl> [flags]           flags are comma separated in brackets:
+// 	                                          L Last, C Chain, R or R=301|302|307|308 Redirect (R is 302), QSR Query RewriteQueryReplace, QSD=a|b DropQuery
+// 	Example
+// 	# legacy urls
+// 	/old-about.html /about [R=301]
+// 	^/blog/([0-9]+)/(.*)$ /articles/$2?id=$1 [R=301,QSD=utm_source|utm_medium]
+// 	host shop.example.com
+// 	cond header User-Agent (?i)mobile
+// 	/product/{id} /m/product/{id} [L]
+func LoadRewriteFile(path string) error {
+	mutexRewriteFile.Lock()
+	defer mutexRewriteFile.Unlock()
+	_, err := loadRewriteFileInternal(path)
+	return err
+}
+
+// loadRewriteFileInternal to load the file and get its stat before reading so a change during the load is seen by the next poll.
+func loadRewriteFileInternal(path string) (rewriteFileStat, error) {
+	var stat rewriteFileStat
+	if info, err := os.Stat(path); err == nil {
+		stat = rewriteFileStat{ModTime: info.ModTime(), Size: info.Size()}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return stat, err
+	}
+	var listRule []rewriteFileRule
+	var listLine []int
+	var listErr []error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		listRule, listLine, listErr = parseRewriteJson(path, b)
+	} else {
+		listRule, listLine, listErr = parseRewriteText(path, b)
+	}
+
+	initRewriteUrl()
+	mapEngine := make(map[string]*rewriteEngine)
+	var listHost []string
+	for index, value := range listRule {
+		fail := func(message string) {
+			listErr = append(listErr, &RewriteFileError{File: path, Line: listLine[index], Message: message})
+		}
+		host := ""
+		if strings.TrimSpace(value.Host) != "" {
+			hp, err := parseHostPattern(value.Host) //registered only once the whole file is valid
+			if err != nil {
+				fail(err.Error())
+				continue
+			}
+			host = hp.Pattern
+			listHost = append(listHost, value.Host)
+		}
+		if strings.TrimSpace(value.SourceUrl) == "" || strings.TrimSpace(value.TargetUrl) == "" {
+			fail("rewrite rule need both SourceUrl and TargetUrl")
+			continue
+		}
+		compiled, err := compileRewriteRule(host, value.RewriteRule)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		if compiled.Token == nil && !compiled.Glob && compiled.Re == nil { //only the same url path would match
+			fail("source url " + compiled.Rule.SourceUrl + " is not a valid filepath pattern, path param or regular expression")
+			continue
+		}
+		mapEngine[host] = mapEngine[host].with(compiled, -1)
+	}
+	if len(listErr) > 0 {
+		sort.SliceStable(listErr, func(i, j int) bool {
+			var a, b *RewriteFileError
+			return errors.As(listErr[i], &a) && errors.As(listErr[j], &b) && a.Line < b.Line
+		})
+		return stat, errors.Join(listErr...)
+	}
+	for _, value := range listHost {
+		if _, err := addHostPattern(value); err != nil {
+			return stat, err
+		}
+	}
+
+	mutexRewriteUrl.Lock()
+	mapFileRewriteEngine = mapEngine
+	rewriteResultCache.reset(-1)
+	mutexRewriteUrl.Unlock()
+	return stat, nil
+}
+
+// parseRewriteJson to decode the json array one rule at a time to know the line of each rule.
+func parseRewriteJson(path string, b []byte) ([]rewriteFileRule, []int, []error) {
+	lineOf := func(offset int64) int {
+		if offset > int64(len(b)) {
+			offset = int64(len(b))
+		}
+		return bytes.Count(b[:offset], []byte("\n")) + 1
+	}
+	fail := func(offset int64, err error) []error {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		}
+		return []error{&RewriteFileError{File: path, Line: lineOf(offset), Message: err.Error()}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fail(decoder.InputOffset(), err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, nil, fail(0, errors.New("rewrite file must be a json array of rewrite rule"))
+	}
+	var listRule []rewriteFileRule
+	var listLine []int
+	for decoder.More() {
+		start := decoder.InputOffset()
+		for start < int64(len(b)) && strings.IndexByte(" \t\r\n,", b[start]) >= 0 {
+			start++
+		}
+		var rule rewriteFileRule
+		if err := decoder.Decode(&rule); err != nil {
+			return nil, nil, fail(start, err)
+		}
+		listRule = append(listRule, rule)
+		listLine = append(listLine, lineOf(start))
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, fail(decoder.InputOffset(), err)
+	}
+	return listRule, listLine, nil
+}
+
+// parseRewriteText to parse the text rewrite file described in LoadRewriteFile.
+func parseRewriteText(path string, b []byte) ([]rewriteFileRule, []int, []error) {
+	var listRule []rewriteFileRule
+	var listLine []int
+	var listErr []error
+	var host string
+	var condition []RewriteCondition
+	conditionLine := 0
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		fail := func(message string) {
+			listErr = append(listErr, &RewriteFileError{File: path, Line: line, Message: message})
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		field := strings.Fields(text)
+		switch field[0] {
+		case "host":
+			if len(field) > 2 {
+				fail("host need one host pattern")
+				continue
+			}
+			host = ""
+			if len(field) == 2 {
+				host = field[1]
+			}
+		case "cond":
+			value, err := parseRewriteCondition(field[1:], text)
+			if err != nil {
+				fail(err.Error())
+				continue
+			}
+			if len(condition) == 0 {
+				conditionLine = line
+			}
+			condition = append(condition, value)
+		default:
+			if len(field) < 2 || len(field) > 3 {
+				fail("rewrite rule must be <SourceUrl> <TargetUrl> [flags]")
+				condition = nil
+				continue
+			}
+			rule := rewriteFileRule{Host: host, RewriteRule: RewriteRule{SourceUrl: field[0], TargetUrl: field[1], Condition: condition}}
+			condition = nil
+			if len(field) == 3 {
+				if err := parseRewriteFlag(field[2], &rule.RewriteRule); err != nil {
+					fail(err.Error())
+					continue
+				}
+			}
+			listRule = append(listRule, rule)
+			listLine = append(listLine, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		listErr = append(listErr, err)
+	}
+	if len(condition) > 0 {
+		listErr = append(listErr, &RewriteFileError{File: path, Line: conditionLine, Message: "cond without a rewrite rule after it"})
+	}
+	return listRule, listLine, listErr
+}
+
+// parseRewriteCondition to parse the fields after cond, the pattern is taken from text to keep its spaces.
+func parseRewriteCondition(field []string, text string) (RewriteCondition, error) {
+	var value RewriteCondition
+	if len(field) == 0 {
+		return value, errors.New("cond need a kind")
+	}
+	value.Kind = field[0]
+	if strings.HasPrefix(value.Kind, "!") {
+		value.Negate, value.Kind = true, value.Kind[1:]
+	}
+	skip := 2 //cond and kind
+	switch value.Kind {
+	case RewriteCondHost, RewriteCondMethod:
+		if len(field) < 2 {
+			return value, errors.New("cond " + value.Kind + " need a pattern")
+		}
+	case RewriteCondHeader, RewriteCondQuery, RewriteCondCookie:
+		if len(field) < 2 {
+			return value, errors.New("cond " + value.Kind + " need a name")
+		}
+		value.Name = field[1]
+		skip++
+	default:
+		return value, errors.New("unknown cond kind " + value.Kind)
+	}
+	rest := text
+	for i := 0; i < skip; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		if index := strings.IndexAny(rest, " \t"); index >= 0 {
+			rest = rest[index:]
+		} else {
+			rest = ""
+		}
+	}
+	value.Pattern = strings.TrimSpace(rest)
+	return value, nil
+}
+
+// parseRewriteFlag to set the rule from flags like [R=301,L].
+func parseRewriteFlag(flag string, rule *RewriteRule) error {
+	if !strings.HasPrefix(flag, "[") || !strings.HasSuffix(flag, "]") {
+		return errors.New("flags must be in brackets e.g [R=301,L] not " + flag)
+	}
+	for _, value := range strings.Split(flag[1:len(flag)-1], ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(value), "=")
+		switch strings.ToUpper(name) {
+		case "L":
+			rule.Last = true
+		case "C":
+			rule.Chain = true
+		case "R":
+			rule.Redirect = http.StatusFound
+			if param != "" {
+				code, err := strconv.Atoi(param)
+				if err != nil {
+					return errors.New("redirect status code " + param + " is not a number")
+				}
+				rule.Redirect = code
+			}
+		case "QSR":
+			rule.Query = RewriteQueryReplace
+		case "QSD":
+			if param == "" {
+				return errors.New("QSD need the query params to drop e.g QSD=utm_source|utm_medium")
+			}
+			rule.DropQuery = append(rule.DropQuery, strings.Split(param, "|")...)
+		default:
+			return errors.New("unknown flag " + value)
+		}
+	}
+	return nil
+}
+
+// same to check the modification time and size are the same.
+func (s rewriteFileStat) same(other rewriteFileStat) bool {
+	return s.ModTime.Equal(other.ModTime) && s.Size == other.Size
+}
+
+// change to check if the file must be reloaded by this poll. a change is only reloaded once the file is the same on two polls in a row so a file being written is not loaded half way,
+// and an empty file is never reloaded by the poll as it is more likely truncated than meant to drop every rule, SIGHUP or LoadRewriteFile load it.
+func (p *rewriteFilePoll) change(current rewriteFileStat) bool {
+	if current.same(p.Loaded) {
+		p.Pending = current
+		return false
+	}
+	if !current.same(p.Pending) {
+		p.Pending = current
+		return false
+	}
+	if current.Size == 0 {
+		log.Print("error reload rewrite file " + p.Path + ", empty file ignored, send SIGHUP to load it")
+		p.Loaded = current //not logged again until the file change
+		return false
+	}
+	return true
+}
+
+// watchRewriteFile to load the rewrite file then reload it when its modification time or size change, see rewriteFilePoll, or on SIGHUP until Reset.
+// a reload with an invalid rule is logged and the current rules are kept.
+func watchRewriteFile(path string) {
+	load := func(reason string) rewriteFileStat {
+		mutexRewriteFile.Lock()
+		defer mutexRewriteFile.Unlock()
+		stat, err := loadRewriteFileInternal(path)
+		if err != nil {
+			log.Print("error " + reason + " rewrite file " + path + ", current rules kept:\n" + err.Error())
+		} else {
+			log.Print(reason + " rewrite file " + path)
+		}
+		return stat
+	}
+	poll := &rewriteFilePoll{Path: path, Loaded: load("load")}
+
+	stop := make(chan struct{})
+	mutexRewriteUrl.Lock()
+	stopRewriteFile = stop
+	mutexRewriteUrl.Unlock()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		ticker := time.NewTicker(RewriteFilePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hangup:
+				poll.Loaded = load("reload")
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || !poll.change(rewriteFileStat{ModTime: info.ModTime(), Size: info.Size()}) {
+					continue
+				}
+				poll.Loaded = load("reload")
+			}
+		}
+	}()
+}
+
+// initRewriteFile to watch the file of the json attribute UrlRewriteFile in config.json once.
+func initRewriteFile(path string) {
+	if path == "" {
+		return
+	}
+	onceRewriteFile.Do(func() {
+		watchRewriteFile(path)
+	})
+}
@@ -0,0 +1,136 @@
+package httpUtil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRewriteFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRewriteFileText(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	path := writeRewriteFile(t, "rewrite.txt", `# legacy urls
+/old-about.html /about [R=301]
+^/blog/([0-9]+)$ /articles?id=$1 [L]
+host shop.example.com
+cond method GET
+/product/{id} /m/product/{id}
+`)
+	if err := LoadRewriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{"": "/product/7", "shop.example.com": "/m/product/7"} {
+		if got := GetRewriteUrlTargetByHost(host, "/product/7"); got != want {
+			t.Errorf("GetRewriteUrlTargetByHost(%q, /product/7) = %q, want %q", host, got, want)
+		}
+	}
+	if got := GetRewriteUrlTarget("/blog/12"); got != "/articles?id=12" {
+		t.Errorf("GetRewriteUrlTarget(/blog/12) = %q, want /articles?id=12", got)
+	}
+	if got := GetRewriteUrlTarget("/old-about.html"); got != "/about" {
+		t.Errorf("GetRewriteUrlTarget(/old-about.html) = %q, want /about", got)
+	}
+}
+
+func TestLoadRewriteFileLineError(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	if err := LoadRewriteFile(writeRewriteFile(t, "good.txt", "/a /b\n")); err != nil {
+		t.Fatal(err)
+	}
+	path := writeRewriteFile(t, "bad.txt", `/c /d
+/e
+host *
+/f /g [X]
+cond header
+/h /i
+`)
+	err := LoadRewriteFile(path)
+	if err == nil {
+		t.Fatal("LoadRewriteFile of an invalid file = nil")
+	}
+	var fileErr *RewriteFileError
+	if !errors.As(err, &fileErr) || fileErr.Line != 2 {
+		t.Errorf("first error %v, want line 2", err)
+	}
+	for _, line := range []string{":2: ", ":4: ", ":5: ", ":6: "} {
+		if !strings.Contains(err.Error(), path+line) {
+			t.Errorf("error %q does not report %s", err, path+line)
+		}
+	}
+	if got := GetRewriteUrlTarget("/a"); got != "/b" {
+		t.Errorf("rule of the previous file lost: GetRewriteUrlTarget(/a) = %q", got)
+	}
+	if got := GetRewriteUrlTarget("/c"); got != "/c" {
+		t.Errorf("rule of the invalid file loaded: GetRewriteUrlTarget(/c) = %q", got)
+	}
+}
+
+func TestLoadRewriteFileJsonHostNotRegistered(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	path := writeRewriteFile(t, "rewrite.json", `[
+  {"Host": "api.example.com", "SourceUrl": "/a", "TargetUrl": "/b"},
+  {"SourceUrl": "/c"}
+]`)
+	err := LoadRewriteFile(path)
+	if err == nil || !strings.Contains(err.Error(), path+":3: ") {
+		t.Fatalf("LoadRewriteFile = %v, want an error at line 3", err)
+	}
+	if len(loadRouteTable().HostPattern) != 0 {
+		t.Error("host pattern of an invalid rewrite file registered")
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"Host": "api.example.com", "SourceUrl": "/a", "TargetUrl": "/b"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadRewriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(loadRouteTable().HostPattern) != 1 {
+		t.Error("host pattern of a valid rewrite file not registered")
+	}
+	if got := GetRewriteUrlTargetByHost("api.example.com", "/a"); got != "/b" {
+		t.Errorf("GetRewriteUrlTargetByHost(api.example.com, /a) = %q, want /b", got)
+	}
+}
+
+func TestRewriteFilePoll(t *testing.T) {
+	now := time.Now()
+	loaded := rewriteFileStat{ModTime: now, Size: 10}
+	writing := rewriteFileStat{ModTime: now.Add(time.Second), Size: 4}
+	written := rewriteFileStat{ModTime: now.Add(2 * time.Second), Size: 20}
+	empty := rewriteFileStat{ModTime: now.Add(3 * time.Second)}
+	poll := &rewriteFilePoll{Path: "rewrite.txt", Loaded: loaded}
+	for index, step := range []struct {
+		Current rewriteFileStat
+		Reload  bool
+	}{
+		{loaded, false},
+		{writing, false}, //changed, wait for the next poll
+		{written, false}, //still changing
+		{written, true},  //the same on two polls
+		{empty, false},
+		{empty, false}, //empty is never reloaded by the poll
+		{empty, false},
+	} {
+		if got := poll.change(step.Current); got != step.Reload {
+			t.Errorf("poll %d: change = %v, want %v", index, got, step.Reload)
+		}
+		if step.Reload {
+			poll.Loaded = step.Current
+		}
+	}
+}
@@ -1,89 +1,429 @@
 package httpUtil
 
 import (
-	"path/filepath"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"tiger/config"
+	logUtil "tiger/util/log"
 )
 
+// kind of RewriteCondition
+const (
+	RewriteCondHost   = "host"
+	RewriteCondMethod = "method"
+	RewriteCondHeader = "header"
+	RewriteCondQuery  = "query"
+	RewriteCondCookie = "cookie"
+)
+
+// how the query string of RewriteRule TargetUrl is combined with the request query string
+const (
+	//keep the request query params and set the ones of TargetUrl, the default
+	RewriteQueryMerge = "merge"
+	//only the query params of TargetUrl, none if TargetUrl has no query string
+	RewriteQueryReplace = "replace"
+)
+
+// RewriteRule is one rule of the ordered rewrite rule list, like a mod_rewrite RewriteRule with its RewriteCond.
+// rules are tried in the order added, the host pattern rules of Host(...).AddRewriteRule first. a matching rule rewrite the url path and the next rules are tried on the rewritten url unless Last.
+//
+// TargetUrl can have a query string e.g /find?q=$1&page={?page}, merged with the request query string unless Query is RewriteQueryReplace. captured values are escaped for the query string.
+// 	Example AddRewriteRule(RewriteRule{SourceUrl: "^/search/(.*)$", TargetUrl: "/find?q=$1", DropQuery: []string{"utm_source"}})
+// 	Example AddRewriteRule(RewriteRule{SourceUrl: "/product", SourceQuery: map[string]string{"id": "[0-9]+"}, TargetUrl: "/product/{?id}", Query: RewriteQueryReplace, Redirect: http.StatusMovedPermanently})
+type RewriteRule struct {
+	//same syntax as AddRewriteUrl: exact, filepath pattern, path param or regular expression. only matched against the url path
+	SourceUrl string
+	//query param name to the regular expression its whole value must match, empty only require the param. the value is put in TargetUrl by {?name}
+	SourceQuery map[string]string
+	TargetUrl   string
+	//RewriteQueryMerge (default) or RewriteQueryReplace
+	Query string
+	//query params removed from the rewritten url
+	DropQuery []string
+	//0 for internal rewrite, 301 302 307 308 for external redirect to TargetUrl which can be a path or an absolute url. a redirect stop the rule list
+	Redirect int
+	//every condition must be true for the rule to match
+	Condition []RewriteCondition
+	//stop trying the next rules when this rule match, like [L]
+	Last bool
+	//when this rule does not match, skip the next rules up to the first one without Chain, like [C]
+	Chain bool
+}
+
+// RewriteCondition is a condition of a RewriteRule on the request. the value is matched by the regular expression Pattern, an empty Pattern only require the header, query param or cookie to be present.
+// 	Example RewriteCondition{Kind: RewriteCondHeader, Name: "User-Agent", Pattern: "(?i)mobile"}
+// 	Example RewriteCondition{Kind: RewriteCondMethod, Pattern: "^(GET|HEAD)$"}
+// 	Example RewriteCondition{Kind: RewriteCondCookie, Name: "beta", Negate: true}
+type RewriteCondition struct {
+	//one of RewriteCondHost, RewriteCondMethod, RewriteCondHeader, RewriteCondQuery, RewriteCondCookie
+	Kind string
+	//header, query param or cookie name
+	Name    string
+	Pattern string
+	//true when the condition must not be true
+	Negate bool
+
+	re *regexp.Regexp
+}
+
+// RewriteStep describe one rewrite url applied to the url.
+type RewriteStep struct {
+	Host      string `json:"host,omitempty"`
+	SourceUrl string `json:"sourceUrl"`
+	TargetUrl string `json:"targetUrl"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Redirect  int    `json:"redirect,omitempty"`
+}
+
+type rewriteRule struct {
+	Host        string
+	Rule        RewriteRule
+	SourceQuery map[string]*regexp.Regexp
+	//compiled source url, see compileRewriteSource
+	Token      []*pathParamToken
+	Glob       bool
+	GlobBucket string
+	//the literal start of a glob not starting with /, e.g ^ of ^/search/(.*)$
+	GlobLead   string
+	Re         *regexp.Regexp
+	ReLiteral  string
+	ReBucket   string
+	//the text after the first / of ReLiteral up to the next /, a path segment of the url path must start with it
+	RePrefix string
+	//has a condition on method, header or cookie so its result cannot be cached
+	RequestDependent bool
+}
+
+type rewriteResult struct {
+	Url      string
+	RawQuery string
+	Redirect int
+	Step     []RewriteStep
+}
+
+// rewriteCapture is a placeholder of TargetUrl e.g $1 {id} {?page} and its value.
+type rewriteCapture struct {
+	Placeholder string
+	Value       string
+}
+
 var onceRewriteUrl sync.Once
-var mapRewriteUrl map[string]string
+var defaultRewriteEngine *rewriteEngine
+var mapHostRewriteEngine map[string]*rewriteEngine
 var mutexRewriteUrl sync.RWMutex
 
 // AddRewriteUrl. sourceUrl parameter placeholder syntax is () targetUrl parameter substituition syntax is $1 $ 2 etc.
+// it add an internal rewrite RewriteRule with Last so the first matching rewrite url win. adding the same sourceUrl again replace its targetUrl.
 // 	Example sourceUrl /(id)  and targetUrl /$1
 func AddRewriteUrl(sourceUrl string, targetUrl string) {
+	addRewriteUrlInternal("", sourceUrl, targetUrl)
+}
+
+// AddRewriteRule to add a rule at the end of the ordered rewrite rule list. rules are compiled once and indexed by their literal first path segment so thousands of rules stay cheap,
+// a regular expression source url is only indexed if it start with ^ and a literal segment e.g ^/shop/(.*)
+// 	Example
+// 	AddRewriteRule(RewriteRule{SourceUrl: "/old/{page}", TargetUrl: "/new/{page}", Redirect: http.StatusMovedPermanently})
+// 	AddRewriteRule(RewriteRule{SourceUrl: "^/(.*)$", TargetUrl: "/m/$1", Last: true, Condition: []RewriteCondition{{Kind: RewriteCondHeader, Name: "User-Agent", Pattern: "(?i)mobile"}}})
+func AddRewriteRule(rule RewriteRule) {
+	addRewriteRuleInternal("", rule, false)
+}
+
+func addRewriteUrlInternal(host string, sourceUrl string, targetUrl string) {
+	addRewriteRuleInternal(host, RewriteRule{SourceUrl: sourceUrl, TargetUrl: targetUrl, Last: true}, true)
+}
+
+// addRewriteRuleInternal to add the rule, replacing the plain rewrite url of the same SourceUrl if replace.
+func addRewriteRuleInternal(host string, rule RewriteRule, replace bool) {
 	initRewriteUrl()
+	value, err := compileRewriteRule(host, rule)
+	if err != nil {
+		log.Print("error " + err.Error())
+		return
+	}
+	rule = value.Rule
+
 	mutexRewriteUrl.Lock()
 	defer mutexRewriteUrl.Unlock()
-	mapRewriteUrl[strings.TrimSpace(sourceUrl)] = strings.TrimSpace(targetUrl)
+	engine := defaultRewriteEngine
+	if host != "" {
+		engine = mapHostRewriteEngine[host]
+	}
+	index := -1
+	if replace && engine != nil {
+		for i, existing := range engine.Rule {
+			if existing.Rule.SourceUrl == rule.SourceUrl && existing.Rule.Redirect == 0 && len(existing.Rule.Condition) == 0 && len(existing.SourceQuery) == 0 {
+				index = i
+				break
+			}
+		}
+	}
+	if host == "" {
+		defaultRewriteEngine = engine.with(value, index)
+	} else {
+		mapHostRewriteEngine[host] = engine.with(value, index)
+	}
+	rewriteResultCache.reset(-1)
+}
+
+// compileRewriteRule to validate the rule and compile its regular expressions and source url.
+func compileRewriteRule(host string, rule RewriteRule) (*rewriteRule, error) {
+	initMapHandler() //path param parsing
+	rule.SourceUrl = strings.TrimSpace(rule.SourceUrl)
+	rule.TargetUrl = strings.TrimSpace(rule.TargetUrl)
+	switch rule.Redirect {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, errors.New("redirect status code " + strconv.Itoa(rule.Redirect) + " of rewrite rule " + rule.SourceUrl)
+	}
+	condition := make([]RewriteCondition, len(rule.Condition))
+	for index, value := range rule.Condition {
+		switch value.Kind {
+		case RewriteCondHost, RewriteCondMethod, RewriteCondHeader, RewriteCondQuery, RewriteCondCookie:
+		default:
+			return nil, errors.New("condition kind " + value.Kind + " of rewrite rule " + rule.SourceUrl)
+		}
+		re, err := regexp.Compile(value.Pattern)
+		if err != nil {
+			return nil, errors.New("condition pattern of rewrite rule " + rule.SourceUrl + ": " + err.Error())
+		}
+		value.re = re
+		condition[index] = value
+	}
+	rule.Condition = condition
+	switch rule.Query {
+	case "":
+		rule.Query = RewriteQueryMerge
+	case RewriteQueryMerge, RewriteQueryReplace:
+	default:
+		return nil, errors.New("query " + rule.Query + " of rewrite rule " + rule.SourceUrl)
+	}
+	sourceQuery := make(map[string]*regexp.Regexp)
+	for name, pattern := range rule.SourceQuery {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.New("source query " + name + " of rewrite rule " + rule.SourceUrl + ": " + err.Error())
+		}
+		sourceQuery[name] = re
+	}
+
+	value := &rewriteRule{Host: host, Rule: rule, SourceQuery: sourceQuery}
+	for _, condition := range rule.Condition {
+		value.RequestDependent = value.RequestDependent || (condition.Kind != RewriteCondHost && condition.Kind != RewriteCondQuery)
+	}
+	compileRewriteSource(value)
+	return value, nil
 }
 
-// GetRewriteUrlTarget to get the target rewritten url based on the sourceUrl parameter.
+// GetRewriteUrlTarget to get the target rewritten url based on the sourceUrl parameter. Only the rewrite url of the default host are used.
 func GetRewriteUrlTarget(sourceUrl string) string {
+	return GetRewriteUrlTargetByHost("", sourceUrl)
+}
+
+// GetRewriteUrlTargetByHost to get the target rewritten url based on the request host and sourceUrl parameter.
+// rewrite url added through Host(...).AddRewriteUrl are tried first when the host match, then the default host rewrite url.
+// rules with a condition other than host are evaluated against a GET request without header and cookie. a redirect rule give its redirect url.
+// sourceUrl can have a query string, the rewritten query string is returned after ? if not empty.
+func GetRewriteUrlTargetByHost(host string, sourceUrl string) string {
+	path, rawQuery, _ := strings.Cut(strings.TrimSpace(sourceUrl), "?")
+	r := &http.Request{Method: http.MethodGet, Host: host, URL: &url.URL{Path: path, RawQuery: rawQuery}, Header: make(http.Header)}
+	result := traceRewriteUrl(r, path)
+	if result.Redirect != 0 {
+		return rewriteLocation(result)
+	}
+	return joinQuery(result.Url, result.RawQuery)
+}
+
+// RewriteUrlHandler to apply the rewrite rules to the url path and query string of every request before calling next when the json attribute UrlRewrite is true in config.json.
+// the rules of the file of the json attribute UrlRewriteFile are loaded, then reloaded when the file change or on SIGHUP, see LoadRewriteFile.
+// a redirect rule respond with the redirect.
+func RewriteUrlHandler(c *config.Config, next http.Handler) http.Handler {
+	if !c.Site.UrlRewrite {
+		return next
+	}
+	initRewriteFile(c.Site.UrlRewriteFile)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logUtil.DebugPrintln("rewrite incoming url: " + joinQuery(r.URL.Path, r.URL.RawQuery))
+		result := traceRewriteUrl(r, r.URL.Path)
+		if result.Redirect != 0 {
+			target := rewriteLocation(result)
+			logUtil.DebugPrintln("rewrite redirect url: " + target)
+			http.Redirect(w, r, target, result.Redirect)
+			return
+		}
+		if result.Url != r.URL.Path {
+			r.URL.Path, r.URL.RawPath = result.Url, ""
+		}
+		r.URL.RawQuery = result.RawQuery
+		logUtil.DebugPrintln("rewrite outgoing url: " + joinQuery(r.URL.Path, r.URL.RawQuery))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceRewriteUrl to apply the rules of the request host then the default host rules in order to sourceUrl and the request query string.
+// the rules of the rewrite file of a host pattern are applied before its rules added by code.
+// a Chain does not continue from the rules of a host pattern to the next host pattern or the default host rules.
+func traceRewriteUrl(r *http.Request, sourceUrl string) rewriteResult {
 	initRewriteUrl()
-	mutexRewriteUrl.RLock()
-	defer mutexRewriteUrl.RUnlock()
 	sourceUrl = strings.TrimSpace(sourceUrl)
-	for src, tgt := range mapRewriteUrl {
-		if ok, url := matchRewriteUrlSource(sourceUrl, src, tgt); ok {
-			return url
-		}
-	}
-	return sourceUrl
-}
-
-func matchRewriteUrlSource(incomingSourceUrl, mapSourceUrl, mapTargetUrl string) (bool, string) {
-	var (
-		found bool
-	)
-	if mapSourceUrl == incomingSourceUrl { //direct match
-		return true, mapTargetUrl
-	}
-	if found, _ = filepath.Match(mapSourceUrl, incomingSourceUrl); found { //filepath match
-		return true, mapTargetUrl
-	}
-
-	actualToken := splitBySlashToken(incomingSourceUrl)
-	mapSourceToken := splitBySlashToken(mapSourceUrl)
-	var pathParam = make(map[string]string)
-	found = false
-	if len(mapSourceToken) == len(actualToken) { //path param match
-		for index, value := range mapSourceToken {
-			if matched := pathParamRE.MatchString(value); matched {
-				pathParam[value] = actualToken[index]
-				found = true
-			} else {
-				if value != actualToken[index] {
-					found = false
-					break
-				}
-			}
+	key := r.Host + "\x00" + joinQuery(sourceUrl, r.URL.RawQuery)
+	if result, found := rewriteResultCache.get(key); found {
+		return result
+	}
+	matched := matchHost(r.Host)
+	mutexRewriteUrl.RLock()
+	var engine []*rewriteEngine
+	for _, hm := range matched {
+		if value := mapFileRewriteEngine[hm.Pattern]; value != nil {
+			engine = append(engine, value)
+		}
+		if value := mapHostRewriteEngine[hm.Pattern]; value != nil {
+			engine = append(engine, value)
 		}
 	}
-	if found {
-		for placeholder, value := range pathParam {
-			mapTargetUrl = strings.ReplaceAll(mapTargetUrl, placeholder, value)
+	engine = append(engine, mapFileRewriteEngine[""], defaultRewriteEngine)
+	mutexRewriteUrl.RUnlock()
+
+	result := rewriteResult{Url: sourceUrl, RawQuery: r.URL.RawQuery}
+	cacheable := true
+	for _, value := range engine {
+		stop, ok := value.apply(r, &result)
+		cacheable = cacheable && ok
+		if stop {
+			break
 		}
-		return true, mapTargetUrl
 	}
+	if cacheable {
+		rewriteResultCache.put(key, result)
+	}
+	return result
+}
 
-	if srcRe, err := regexp.Compile(mapSourceUrl); err == nil {
-		if matched := srcRe.FindStringSubmatch(incomingSourceUrl); matched != nil {
-			for index, value := range matched {
-				mapTargetUrl = strings.ReplaceAll(mapTargetUrl, "$"+strconv.Itoa(index), value)
-			}
-			return true, mapTargetUrl
+// matchRewriteQuery to check every source query param match and add their value as {?name} capture.
+func matchRewriteQuery(rawQuery string, sourceQuery map[string]*regexp.Regexp, capture []rewriteCapture) (bool, []rewriteCapture) {
+	if len(sourceQuery) == 0 {
+		return true, capture
+	}
+	query, _ := url.ParseQuery(rawQuery)
+	for name, re := range sourceQuery {
+		value, found := query[name]
+		if !found || !re.MatchString(value[0]) {
+			return false, nil
 		}
+		capture = append(capture, rewriteCapture{Placeholder: "{?" + name + "}", Value: value[0]})
 	}
+	return true, capture
+}
 
-	return false, ""
+// expandRewriteTarget to put the captured values in TargetUrl and combine its query string with rawQuery.
+// the url path is not escaped as it is the decoded url path, the query string is encoded so captured & = # do not change it.
+// an absolute TargetUrl give the scheme and host in front of the url path.
+func expandRewriteTarget(rule *RewriteRule, capture []rewriteCapture, rawQuery string) (string, string) {
+	pair := make([]string, 0, len(capture)*2)
+	for _, value := range capture {
+		pair = append(pair, value.Placeholder, value.Value)
+	}
+	replacer := strings.NewReplacer(pair...)
+	target, targetQuery, hasQuery := rule.TargetUrl, "", false
+	for index := 0; index < len(target); index++ {
+		if target[index] == '?' && (index == 0 || target[index-1] != '{') { //not a {?name} placeholder
+			target, targetQuery, hasQuery = target[:index], target[index+1:], true
+			break
+		}
+	}
+	target = replacer.Replace(target)
+	if !hasQuery && len(rule.DropQuery) == 0 && rule.Query != RewriteQueryReplace {
+		return target, rawQuery //untouched so the order and encoding of the request query string are kept
+	}
+	query := make(url.Values)
+	if rule.Query != RewriteQueryReplace {
+		query, _ = url.ParseQuery(rawQuery)
+	}
+	set := make(map[string]bool)
+	for _, value := range strings.Split(targetQuery, "&") {
+		if value == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(value, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(param); err == nil {
+			param = unescaped
+		}
+		name, param = replacer.Replace(name), replacer.Replace(param)
+		if !set[name] { //the first one replace the request value, the next ones are added
+			query.Del(name)
+			set[name] = true
+		}
+		query.Add(name, param)
+	}
+	for _, name := range rule.DropQuery {
+		query.Del(name)
+	}
+	return target, query.Encode()
+}
+
+// rewriteLocation to get the escaped redirect url of the result.
+// a path starting with // e.g /$1 of /old//evil.com is collapsed to one / as the client would take //evil.com as another host.
+func rewriteLocation(result rewriteResult) string {
+	prefix, path := "", result.Url
+	if index := strings.Index(path, "://"); index >= 0 && !strings.Contains(path[:index], "/") {
+		if slash := strings.Index(path[index+3:], "/"); slash >= 0 {
+			prefix, path = path[:index+3+slash], path[index+3+slash:]
+		} else {
+			prefix, path = path, ""
+		}
+	} else if strings.HasPrefix(path, "//") {
+		path = "/" + strings.TrimLeft(path, "/")
+	}
+	return joinQuery(prefix+(&url.URL{Path: path}).EscapedPath(), result.RawQuery)
+}
+
+func joinQuery(path string, rawQuery string) string {
+	if rawQuery == "" {
+		return path
+	}
+	return path + "?" + rawQuery
+}
+
+// matchRewriteCondition to check every condition is true for the request.
+func matchRewriteCondition(r *http.Request, condition []RewriteCondition) bool {
+	for _, value := range condition {
+		var actual string
+		present := true
+		switch value.Kind {
+		case RewriteCondHost:
+			actual = r.Host
+		case RewriteCondMethod:
+			actual = r.Method
+		case RewriteCondHeader:
+			_, present = r.Header[http.CanonicalHeaderKey(value.Name)]
+			actual = r.Header.Get(value.Name)
+		case RewriteCondQuery:
+			_, present = r.URL.Query()[value.Name]
+			actual = r.URL.Query().Get(value.Name)
+		case RewriteCondCookie:
+			cookie, err := r.Cookie(value.Name)
+			if present = err == nil; present {
+				actual = cookie.Value
+			}
+		}
+		if (present && value.re.MatchString(actual)) == value.Negate {
+			return false
+		}
+	}
+	return true
 }
 
 func initRewriteUrl() {
 	onceRewriteUrl.Do(func() { //singleton
-		mapRewriteUrl = make(map[string]string)
+		mapHostRewriteEngine = make(map[string]*rewriteEngine)
 	})
 }
@@ -0,0 +1,124 @@
+package httpUtil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewriteRuleOrder(t *testing.T) {
+	for name, test := range map[string]struct {
+		Rule []RewriteRule
+		Path map[string]string
+	}{
+		"next rules on the rewritten url": {[]RewriteRule{
+			{SourceUrl: "/a", TargetUrl: "/b"},
+			{SourceUrl: "/b", TargetUrl: "/c"},
+		}, map[string]string{"/a": "/c", "/b": "/c"}},
+		"earlier rules not tried again": {[]RewriteRule{
+			{SourceUrl: "/b", TargetUrl: "/c"},
+			{SourceUrl: "/a", TargetUrl: "/b"},
+		}, map[string]string{"/a": "/b", "/b": "/c"}},
+		"last": {[]RewriteRule{
+			{SourceUrl: "/a", TargetUrl: "/b", Last: true},
+			{SourceUrl: "/b", TargetUrl: "/c"},
+		}, map[string]string{"/a": "/b", "/b": "/c"}},
+		"chain": {[]RewriteRule{
+			{SourceUrl: "^/shop/(.*)$", TargetUrl: "/store/$1", Chain: true},
+			{SourceUrl: "^/store/(.*)$", TargetUrl: "/catalog/$1"},
+			{SourceUrl: "^/catalog/x$", TargetUrl: "/end"},
+		}, map[string]string{"/shop/x": "/end", "/shop/y": "/catalog/y", "/store/y": "/store/y", "/catalog/x": "/end"}},
+		"redirect stop the rule list": {[]RewriteRule{
+			{SourceUrl: "/old", TargetUrl: "/new", Redirect: http.StatusMovedPermanently},
+			{SourceUrl: "/new", TargetUrl: "/newer"},
+		}, map[string]string{"/old": "/new", "/new": "/newer"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Reset()
+			t.Cleanup(Reset)
+			for _, rule := range test.Rule {
+				AddRewriteRule(rule)
+			}
+			for path, want := range test.Path {
+				if got := GetRewriteUrlTarget(path); got != want {
+					t.Errorf("GetRewriteUrlTarget(%s) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteRuleHandler(t *testing.T) {
+	c := newTestConfig()
+	c.Site.UrlRewrite = true
+	handler := newTestHandler(t, c, func() {
+		AddHandler("/new", echoPathHandler(), http.MethodGet, http.MethodPost)
+		AddHandler("/mobile", echoPathHandler())
+		AddRewriteRule(RewriteRule{SourceUrl: "/old", TargetUrl: "/new", Redirect: http.StatusMovedPermanently})
+		AddRewriteRule(RewriteRule{SourceUrl: "/form", TargetUrl: "/new", Condition: []RewriteCondition{{Kind: RewriteCondMethod, Pattern: "^POST$"}}})
+		AddRewriteRule(RewriteRule{SourceUrl: "/home", TargetUrl: "/mobile", Condition: []RewriteCondition{{Kind: RewriteCondHeader, Name: "User-Agent", Pattern: "(?i)mobile"}}})
+	})
+	if w := serveTest(handler, http.MethodGet, "/old?x=1"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/new?x=1" {
+		t.Errorf("GET /old?x=1 = %d %q, want 301 /new?x=1", w.Code, w.Header().Get("Location"))
+	}
+	if w := serveTest(handler, http.MethodPost, "/form"); w.Body.String() != "POST /new" {
+		t.Errorf("POST /form = %q, want POST /new", w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/form"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /form = %d, want 404 as the rule is only for POST", w.Code)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/home", nil)
+	r.Header.Set("User-Agent", "Tiger Mobile")
+	if handler.ServeHTTP(w, r); w.Body.String() != "GET /mobile" {
+		t.Errorf("GET /home from mobile = %q, want GET /mobile", w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/home"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /home = %d, want 404", w.Code)
+	}
+}
+
+func TestRewriteRuleQuery(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	AddRewriteRule(RewriteRule{SourceUrl: "^/search/(.*)$", TargetUrl: "/find?q=$1", DropQuery: []string{"utm_source"}})
+	AddRewriteRule(RewriteRule{SourceUrl: "/product", SourceQuery: map[string]string{"id": "[0-9]+"}, TargetUrl: "/product/{?id}", Query: RewriteQueryReplace})
+	AddRewriteRule(RewriteRule{SourceUrl: "/tag", TargetUrl: "/tags?tag=a&tag=b"})
+	AddRewriteRule(RewriteRule{SourceUrl: "/keep", TargetUrl: "/kept"})
+	AddRewriteRule(RewriteRule{SourceUrl: "^/go/(.*)$", TargetUrl: "/to/$1?from=$1", Redirect: http.StatusFound})
+	for source, want := range map[string]string{
+		"/search/go?page=2":               "/find?page=2&q=go",
+		"/search/a&b=c#d?page=2":          "/find?page=2&q=a%26b%3Dc%23d",
+		"/search/a b?utm_source=x&page=1": "/find?page=1&q=a+b",
+		"/product?id=12&x=1":              "/product/12",
+		"/product?id=ab":                  "/product?id=ab",
+		"/product":                        "/product",
+		"/tag?tag=z&x=1":                  "/tags?tag=a&tag=b&x=1",
+		"/keep?z=1&a=%41":                 "/kept?z=1&a=%41",
+		"/go/a b":                         "/to/a%20b?from=a+b",
+	} {
+		if got := GetRewriteUrlTarget(source); got != want {
+			t.Errorf("GetRewriteUrlTarget(%s) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestRewriteRuleRedirectHost(t *testing.T) {
+	c := newTestConfig()
+	c.Site.UrlRewrite = true
+	handler := newTestHandler(t, c, func() {
+		AddRewriteRule(RewriteRule{SourceUrl: "^/old/(.*)$", TargetUrl: "/$1", Redirect: http.StatusFound})
+		AddRewriteRule(RewriteRule{SourceUrl: "^/ext/(.*)$", TargetUrl: "https://example.com/$1", Redirect: http.StatusFound})
+	})
+	for target, want := range map[string]string{
+		"/old//evil.com":        "/evil.com",
+		"/old///evil.com/a?x=1": "/evil.com/a?x=1",
+		"/old/%5C%5Cevil.com":   "/%5C%5Cevil.com",
+		"/old/page":             "/page",
+		"/ext//page":            "https://example.com//page",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("GET %s = %d %q, want 302 %q", target, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+}
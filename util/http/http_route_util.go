@@ -0,0 +1,440 @@
+package httpUtil
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// routeTable contain the url mapping of either the default host or one host pattern.
+type routeTable struct {
+	MapHandler          map[string]http.Handler
+	MapHandlerRegEx     map[string]http.Handler
+	MapHandlerPathParam map[string]http.Handler
+
+	//lower case url mapping to url mapping of MapHandler. only built inside routeSnapshot for case insensitive match
+	MapHandlerFold map[string]string
+	//MapHandlerPathParam sorted by lessPathParamToken so the most specific url mapping match first. only built inside routeSnapshot
+	ListPathParam []*httpVerbHandler
+}
+
+// routeSnapshot is an immutable copy of all the route tables and host patterns. it is swapped atomically on every add/remove/replace so request never need to take a lock to read it.
+// a route table not changed since the previous routeSnapshot is shared with it.
+type routeSnapshot struct {
+	Default     *routeTable
+	Host        map[string]*routeTable
+	HostPattern []*hostPattern //most specific first
+	Policy      pathPolicy
+}
+
+var routeTableValue atomic.Value
+
+// host of the route tables changed since the last routeSnapshot, nil when all of them must be copied. guarded by mutexHttp
+var routeTableChanged map[string]bool
+
+// routeTableBatch is true until NewServeMux publish the url mapping registered before it at once. guarded by mutexHttp
+var routeTableBatch bool
+
+// routeTablePending is 1 when a change is waiting for the next routeSnapshot during routeTableBatch
+var routeTablePending int32
+
+func (t *routeTable) put(handler *httpVerbHandler) {
+	switch handler.MatchKind {
+	case matchPathParam:
+		t.MapHandlerPathParam[handler.UrlMapping] = handler
+	case matchRegEx:
+		t.MapHandlerRegEx[handler.UrlMapping] = handler
+	default:
+		t.MapHandler[handler.UrlMapping] = handler
+	}
+}
+
+func (t *routeTable) get(urlMapping string) *httpVerbHandler {
+	for _, value := range []map[string]http.Handler{t.MapHandler, t.MapHandlerPathParam, t.MapHandlerRegEx} {
+		if handler, found := value[urlMapping].(*httpVerbHandler); found {
+			return handler
+		}
+	}
+	return nil
+}
+
+func (t *routeTable) remove(urlMapping string) bool {
+	removed := false
+	for _, value := range []map[string]http.Handler{t.MapHandler, t.MapHandlerPathParam, t.MapHandlerRegEx} {
+		if _, found := value[urlMapping]; found {
+			delete(value, urlMapping)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (t *routeTable) clone() *routeTable {
+	table := &routeTable{
+		MapHandler:          make(map[string]http.Handler, len(t.MapHandler)),
+		MapHandlerRegEx:     make(map[string]http.Handler, len(t.MapHandlerRegEx)),
+		MapHandlerPathParam: make(map[string]http.Handler, len(t.MapHandlerPathParam)),
+		MapHandlerFold:      make(map[string]string, len(t.MapHandler)),
+	}
+	for key, value := range t.MapHandler {
+		table.MapHandler[key] = value
+		table.MapHandlerFold[strings.ToLower(key)] = key
+	}
+	for key, value := range t.MapHandlerRegEx {
+		table.MapHandlerRegEx[key] = value
+	}
+	for key, value := range t.MapHandlerPathParam {
+		table.MapHandlerPathParam[key] = value
+		if handler, found := value.(*httpVerbHandler); found {
+			table.ListPathParam = append(table.ListPathParam, handler)
+		}
+	}
+	sort.Slice(table.ListPathParam, func(i, j int) bool {
+		a, b := table.ListPathParam[i], table.ListPathParam[j]
+		if lessPathParamToken(a.PathToken, b.PathToken) {
+			return true
+		}
+		if lessPathParamToken(b.PathToken, a.PathToken) {
+			return false
+		}
+		return a.UrlMapping < b.UrlMapping //same specificity, stable order
+	})
+	return table
+}
+
+// changeRouteTable to publish the change of the route table of host or host pattern. before NewServeMux it is only recorded so the url mapping are published at once. caller must hold mutexHttp.Lock
+func changeRouteTable(host string) {
+	if routeTableChanged != nil {
+		routeTableChanged[host] = true
+	}
+	if routeTableBatch {
+		atomic.StoreInt32(&routeTablePending, 1)
+		return
+	}
+	publishRouteTable()
+}
+
+// publishRouteTable to copy the changed route tables into a new routeSnapshot and swap it in. caller must hold mutexHttp.Lock
+func publishRouteTable() {
+	previous, _ := routeTableValue.Load().(*routeSnapshot)
+	if previous == nil {
+		routeTableChanged = nil
+	}
+	snapshot := &routeSnapshot{Host: make(map[string]*routeTable, len(mapHostRouteTable)), Policy: currentPathPolicy}
+	if routeTableChanged == nil || routeTableChanged[""] {
+		snapshot.Default = getRouteTable("", false).clone()
+	} else {
+		snapshot.Default = previous.Default
+	}
+	for host, table := range mapHostRouteTable {
+		if routeTableChanged != nil && !routeTableChanged[host] && previous.Host[host] != nil {
+			snapshot.Host[host] = previous.Host[host]
+		} else {
+			snapshot.Host[host] = table.clone()
+		}
+	}
+	mutexHost.RLock()
+	snapshot.HostPattern = listHostPattern
+	mutexHost.RUnlock()
+	routeTableChanged = make(map[string]bool)
+	atomic.StoreInt32(&routeTablePending, 0)
+	routeTableValue.Store(snapshot)
+}
+
+// loadRouteTable to get the current routeSnapshot. the change recorded before NewServeMux are published first so ListRoutes and MatchRoute see them.
+func loadRouteTable() *routeSnapshot {
+	initMapHandler()
+	if atomic.LoadInt32(&routeTablePending) == 1 {
+		mutexHttp.Lock()
+		if atomic.LoadInt32(&routeTablePending) == 1 {
+			publishRouteTable()
+		}
+		mutexHttp.Unlock()
+	}
+	return routeTableValue.Load().(*routeSnapshot)
+}
+
+func removeHandlerInternal(host string, urlMapping ...string) bool {
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	table := getRouteTable(host, false)
+	if table == nil {
+		return false
+	}
+	removed := false
+	for _, value := range urlMapping {
+		existing := table.get(value)
+		if table.remove(value) {
+			removed = true
+		}
+		if existing != nil {
+			removeProxyHandler(existing)
+		}
+	}
+	if removed {
+		changeRouteTable(host)
+	}
+	return removed
+}
+
+// updateRouteInternal to copy the first url mapping found, apply update on the copy and swap it in. update return false to abort.
+func updateRouteInternal(host string, update func(handler *httpVerbHandler) bool, urlMapping ...string) bool {
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	table := getRouteTable(host, false)
+	if table == nil {
+		return false
+	}
+	for _, value := range urlMapping {
+		if existing := table.get(value); existing != nil {
+			replacement := *existing
+			if !update(&replacement) {
+				return false
+			}
+			replacement.compose()
+			table.put(&replacement)
+			changeRouteTable(host)
+			if proxy, ok := existing.NextHandler.(*proxyHandler); ok && replacement.NextHandler != http.Handler(proxy) {
+				removeProxyHandler(existing)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func replaceHandlerInternal(host string, handler http.Handler, chain []ChainNextHandler, httpVerb []string, urlMapping ...string) bool {
+	return updateRouteInternal(host, func(replacement *httpVerbHandler) bool {
+		replacement.NextHandler = handler
+		replacement.ChainNextHandler = chain
+		if len(httpVerb) != 0 {
+			replacement.HttpVerb = nil
+			for _, verb := range httpVerb {
+				if validHttpVerb[verb] {
+					replacement.HttpVerb = append(replacement.HttpVerb, verb)
+				}
+			}
+			return len(replacement.HttpVerb) != 0
+		}
+		return true
+	}, urlMapping...)
+}
+
+// RemoveHandler to remove the url mapping added by any of the Add* func of the default host. Request already being served are not affected. Return false if the url mapping cannot be found.
+// urlMapping must be exactly the same string as when it was added.
+func RemoveHandler(urlMapping string) bool {
+	return removeHandlerInternal("", urlMapping)
+}
+
+// ReplaceHandler to swap the handler of an existing url mapping of the default host while keeping its url syntax (direct, regular expression or path param).
+// httpVerb replace the existing http verbs if passed in else the existing http verbs are kept. Return false if the url mapping cannot be found.
+func ReplaceHandler(urlMapping string, handler http.Handler, httpVerb ...string) bool {
+	return replaceHandlerInternal("", handler, nil, httpVerb, urlMapping)
+}
+
+// ReplaceChainHandler is like ReplaceHandler but swap in a chain of handlers.
+func ReplaceChainHandler(urlMapping string, handler []ChainNextHandler, httpVerb ...string) bool {
+	return replaceHandlerInternal("", nil, handler, httpVerb, urlMapping)
+}
+
+// RemoveHandler is like the package RemoveHandler but for url mapping added through the RouteGroup.
+func (g *RouteGroup) RemoveHandler(urlMapping string) bool {
+	if g.err != nil {
+		return false
+	}
+	return removeHandlerInternal(g.host, g.urlMapping(urlMapping), g.urlMappingRegEx(urlMapping))
+}
+
+// ReplaceHandler is like the package ReplaceHandler but for url mapping added through the RouteGroup.
+func (g *RouteGroup) ReplaceHandler(urlMapping string, handler http.Handler, httpVerb ...string) bool {
+	if g.err != nil {
+		return false
+	}
+	return replaceHandlerInternal(g.host, handler, nil, httpVerb, g.urlMapping(urlMapping), g.urlMappingRegEx(urlMapping))
+}
+
+// ReplaceChainHandler is like the package ReplaceChainHandler but for url mapping added through the RouteGroup.
+func (g *RouteGroup) ReplaceChainHandler(urlMapping string, handler []ChainNextHandler, httpVerb ...string) bool {
+	if g.err != nil {
+		return false
+	}
+	return replaceHandlerInternal(g.host, nil, handler, httpVerb, g.urlMapping(urlMapping), g.urlMappingRegEx(urlMapping))
+}
+
+// RouteInfo describe one url mapping as registered by the Add* func.
+type RouteInfo struct {
+	Host        string   `json:"host,omitempty"`
+	Pattern     string   `json:"pattern"`
+	Kind        string   `json:"kind"`
+	HttpVerb    []string `json:"httpVerb"`
+	Handler     string   `json:"handler,omitempty"`
+	Chain       []string `json:"chain,omitempty"`
+	ChainLength int      `json:"chainLength"`
+	Middleware  []string `json:"middleware,omitempty"`
+	Name        string   `json:"name,omitempty"`
+}
+
+// RouteMatch describe which url mapping a method and url resolve to. see MatchRoute
+type RouteMatch struct {
+	Method    string            `json:"method"`
+	Url       string            `json:"url"`
+	Host      string            `json:"host,omitempty"`
+	Rewrite   []RewriteStep     `json:"rewrite,omitempty"`
+	Path      string            `json:"path"`
+	Redirect  string            `json:"redirect,omitempty"`
+	Matched   bool              `json:"matched"`
+	VerbOk    bool              `json:"verbOk"`
+	Route     *RouteInfo        `json:"route,omitempty"`
+	PathParam map[string]string `json:"pathParam,omitempty"`
+}
+
+func handlerTypeName(handler interface{}) string {
+	switch value := handler.(type) {
+	case *pathTokenAdapter:
+		return fmt.Sprintf("%T", value.PathTokenHandler)
+	case *chainPathTokenAdapter:
+		return fmt.Sprintf("%T", value.ChainPathTokenHandler)
+	case *errorAdapter:
+		if f, found := value.ErrorHandler.(errorHandlerFunc); found {
+			return fmt.Sprintf("%T", ErrorHandlerFunc(f))
+		}
+		return fmt.Sprintf("%T", value.ErrorHandler)
+	case *chainErrorAdapter:
+		return fmt.Sprintf("%T", value.ChainErrorHandler)
+	case *middlewareAdapter:
+		return "Middleware(" + middlewareName(value.Middleware) + ")"
+	case *FanOut:
+		var task []string
+		for _, v := range value.Task {
+			task = append(task, v.Name)
+		}
+		return "FanOut(" + strings.Join(task, ", ") + ")"
+	case *proxyHandler:
+		var upstream []string
+		for _, v := range value.Upstream {
+			upstream = append(upstream, v.Url.String())
+		}
+		return "Proxy(" + value.Route.Balance + " " + strings.Join(upstream, ", ") + ")"
+	case *negotiateHandler:
+		var variant []string
+		for _, v := range value.Variant {
+			name := handlerTypeName(v.Handler)
+			if v.Handler == nil {
+				name = fmt.Sprintf("chain of %d", len(v.ChainNextHandler))
+			}
+			variant = append(variant, strings.TrimSpace("v"+v.Version+" "+strings.Join(v.Produce, " ")+" "+name))
+		}
+		return "Negotiate(" + strings.Join(variant, ", ") + ")"
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+func (a *httpVerbHandler) routeInfo() RouteInfo {
+	info := RouteInfo{Host: a.Host, Pattern: a.UrlMapping, Kind: "exact", HttpVerb: a.HttpVerb, ChainLength: len(a.ChainNextHandler), Name: a.Name}
+	switch a.MatchKind {
+	case matchPathParam:
+		info.Kind = "path-param"
+	case matchRegEx:
+		info.Kind = "regex"
+	default:
+		if strings.ContainsAny(a.UrlMapping, `*?[\`) || strings.HasSuffix(a.UrlMapping, "/") {
+			info.Kind = "glob"
+		}
+	}
+	if a.NextHandler != nil {
+		info.Handler = handlerTypeName(a.NextHandler)
+	}
+	for _, value := range a.ChainNextHandler {
+		info.Chain = append(info.Chain, handlerTypeName(value))
+	}
+	for _, value := range a.Middleware {
+		info.Middleware = append(info.Middleware, middlewareName(value))
+	}
+	return info
+}
+
+// ListRoutes to get all the url mapping of the default host and every host pattern sorted by host then url mapping.
+func ListRoutes() []RouteInfo {
+	snapshot := loadRouteTable()
+	var routes []RouteInfo
+	tables := []*routeTable{snapshot.Default}
+	for _, table := range snapshot.Host {
+		tables = append(tables, table)
+	}
+	for _, table := range tables {
+		for _, value := range []map[string]http.Handler{table.MapHandler, table.MapHandlerPathParam, table.MapHandlerRegEx} {
+			for _, handler := range value {
+				if handler, found := handler.(*httpVerbHandler); found {
+					routes = append(routes, handler.routeInfo())
+				}
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		return routes[i].Pattern < routes[j].Pattern
+	})
+	return routes
+}
+
+// MatchRoute to find which url mapping a request with method and rawUrl would be served by without calling the handler.
+// rawUrl can be a path /user/1 or a full url http://api.example.com/user/1 for host based routing.
+// rewrite true to apply the rewrite url first like when UrlRewrite is enabled in config.json.
+func MatchRoute(method string, rawUrl string, rewrite bool) (*RouteMatch, error) {
+	r, err := http.NewRequest(method, rawUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &RouteMatch{Method: r.Method, Url: rawUrl, Host: r.Host, Path: r.URL.Path}
+	if rewrite {
+		rewritten := traceRewriteUrl(r, r.URL.Path)
+		result.Rewrite = rewritten.Step
+		if rewritten.Redirect != 0 {
+			result.Redirect = rewritten.Url
+			return result, nil
+		}
+		r.URL.Path = rewritten.Url
+		result.Path = r.URL.Path
+	}
+	handler, _, pathParam, redirect, found := resolveHandler(r)
+	if redirect != "" {
+		result.Redirect = redirect
+	}
+	if !found {
+		return result, nil
+	}
+	result.Matched = true
+	result.PathParam = pathParam
+	if value, ok := handler.(*httpVerbHandler); ok {
+		info := value.routeInfo()
+		result.Route = &info
+		result.VerbOk = httpVerbOk(r, value.HttpVerb)
+	}
+	return result, nil
+}
+
+func setRouteNameInternal(host string, name string, urlMapping ...string) bool {
+	return updateRouteInternal(host, func(replacement *httpVerbHandler) bool {
+		replacement.Name = name
+		return true
+	}, urlMapping...)
+}
+
+// SetRouteName to give an existing url mapping of the default host a name shown by ListRoutes. Return false if the url mapping cannot be found.
+func SetRouteName(urlMapping string, name string) bool {
+	return setRouteNameInternal("", name, urlMapping)
+}
+
+// SetRouteName is like the package SetRouteName but for url mapping added through the RouteGroup.
+func (g *RouteGroup) SetRouteName(urlMapping string, name string) bool {
+	if g.err != nil {
+		return false
+	}
+	return setRouteNameInternal(g.host, name, g.urlMapping(urlMapping), g.urlMappingRegEx(urlMapping))
+}
@@ -0,0 +1,103 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRemoveReplaceHandler(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/rm/a", textHandler("a"))
+		AddHandlerPlaceholder("/rm/u/{id}", textHandler("u"))
+		AddHandlerRegEx("^/rm/r/[0-9]+$", textHandler("r"))
+	})
+	if w := serveTest(handler, http.MethodGet, "/rm/a"); w.Body.String() != "a" {
+		t.Fatalf("GET /rm/a = %q, want a", w.Body.String())
+	}
+
+	if !ReplaceHandler("/rm/a", textHandler("a2"), http.MethodGet, http.MethodPost) {
+		t.Fatal("ReplaceHandler /rm/a = false")
+	}
+	if w := serveTest(handler, http.MethodPost, "/rm/a"); w.Body.String() != "a2" {
+		t.Errorf("POST /rm/a after replace = %q, want a2", w.Body.String())
+	}
+	if ReplaceHandler("/rm/missing", textHandler("x")) {
+		t.Error("ReplaceHandler /rm/missing = true")
+	}
+
+	for _, urlMapping := range []string{"/rm/a", "/rm/u/{id}", "^/rm/r/[0-9]+$"} {
+		if !RemoveHandler(urlMapping) {
+			t.Errorf("RemoveHandler %s = false", urlMapping)
+		}
+		if RemoveHandler(urlMapping) {
+			t.Errorf("RemoveHandler %s twice = true", urlMapping)
+		}
+	}
+	for _, target := range []string{"/rm/a", "/rm/u/1", "/rm/r/1"} {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s after remove = %d, want 404", target, w.Code)
+		}
+	}
+}
+
+func TestHostRouteTable(t *testing.T) {
+	var api *RouteGroup
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddHandler("/rt/a", textHandler("default"))
+		api = Host("api.example.com")
+		api.AddHandler("/rt/a", textHandler("api"))
+		Host("{tenant}.example.com").AddHandlerPlaceholder("/rt/t", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "tenant "+PathParam(r, "tenant"))
+		}))
+	})
+	for host, want := range map[string]string{"api.example.com": "api", "API.example.com:8000": "api", "other.test": "default"} {
+		if w := serveHostTest(handler, http.MethodGet, host, "/rt/a"); w.Body.String() != want {
+			t.Errorf("GET %s/rt/a = %q, want %q", host, w.Body.String(), want)
+		}
+	}
+	if w := serveHostTest(handler, http.MethodGet, "acme.example.com", "/rt/t"); w.Body.String() != "tenant acme" {
+		t.Errorf("GET acme.example.com/rt/t = %q, want tenant acme", w.Body.String())
+	}
+
+	before := loadRouteTable()
+	if !api.ReplaceHandler("/rt/a", textHandler("api2")) {
+		t.Fatal("RouteGroup.ReplaceHandler /rt/a = false")
+	}
+	after := loadRouteTable()
+	if after.Default != before.Default || after.Host["{tenant}.example.com"] != before.Host["{tenant}.example.com"] {
+		t.Error("route table of other host copied on replace")
+	}
+	if after.Host["api.example.com"] == before.Host["api.example.com"] {
+		t.Error("route table of replaced host not copied")
+	}
+	if w := serveHostTest(handler, http.MethodGet, "api.example.com", "/rt/a"); w.Body.String() != "api2" {
+		t.Errorf("GET api.example.com/rt/a after replace = %q, want api2", w.Body.String())
+	}
+
+	if !api.RemoveHandler("/rt/a") {
+		t.Fatal("RouteGroup.RemoveHandler /rt/a = false")
+	}
+	if w := serveHostTest(handler, http.MethodGet, "api.example.com", "/rt/a"); w.Body.String() != "default" {
+		t.Errorf("GET api.example.com/rt/a after remove = %q, want default", w.Body.String())
+	}
+}
+
+func TestRouteTableBatch(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	initial := routeTableValue.Load()
+	AddHandler("/a", textHandler("a"))
+	AddHandler("/b", textHandler("b"))
+	if routeTableValue.Load() != initial {
+		t.Error("routeSnapshot published before NewServeMux")
+	}
+	if len(ListRoutes()) != 2 {
+		t.Errorf("ListRoutes before NewServeMux = %d routes, want 2", len(ListRoutes()))
+	}
+	handler := NewHandler(newTestConfig(), nil)
+	AddHandler("/c", textHandler("c"))
+	if w := serveTest(handler, http.MethodGet, "/c"); w.Body.String() != "c" {
+		t.Errorf("GET /c added after NewHandler = %q, want c", w.Body.String())
+	}
+}
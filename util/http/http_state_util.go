@@ -0,0 +1,150 @@
+package httpUtil
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// RequestState is the per request state shared by every global middleware, RouteGroup middleware, chain handler and handler serving the same request. it is safe for concurrent use.
+//
+// lifetime: it is created when the request enter the handler from NewHandler (or the http.ServeMux from NewServeMux) and cleared when that handler return,
+// so the same state is seen across url rewrite, Negotiate, custom error pages and every step of a chain. values must not be kept or read after the request is served,
+// goroutines started by a handler must copy what they need or finish before the handler return.
+type RequestState struct {
+	mutex sync.RWMutex
+	value map[interface{}]interface{}
+}
+
+// StateKey is a typed key of the RequestState. declare it once at package level and use it to Set and Get values of type T.
+// 	Example
+// 	var UserKey = NewStateKey[*User]("user")
+// 	//in an auth ChainNextHandler
+// 	UserKey.Set(r, user)
+// 	//in a later chain handler
+// 	user, found := UserKey.Get(r)
+type StateKey[T any] struct {
+	name string
+}
+
+type requestStateKey struct{}
+
+// NewStateKey to get a StateKey for values of type T. keys with the same name but different type are different keys. name is shown by RequestState.Keys
+func NewStateKey[T any](name string) StateKey[T] {
+	return StateKey[T]{name: name}
+}
+
+// Name to get the name of the key.
+func (k StateKey[T]) Name() string {
+	return k.name
+}
+
+// Set to store the value for the request. it is logged and ignored if the request has no RequestState, see WithState
+func (k StateKey[T]) Set(r *http.Request, value T) {
+	state := GetState(r)
+	if state == nil {
+		log.Print("no request state to set " + k.name + ", serve the request through NewHandler or call WithState")
+		return
+	}
+	state.set(k, value)
+}
+
+// Get to get the value stored for the request. found is false if it was not set.
+func (k StateKey[T]) Get(r *http.Request) (T, bool) {
+	var zero T
+	state := GetState(r)
+	if state == nil {
+		return zero, false
+	}
+	value, found := state.get(k)
+	if !found {
+		return zero, false
+	}
+	typed, ok := value.(T)
+	return typed, ok
+}
+
+// GetOr to get the value stored for the request or defaultValue if it was not set.
+func (k StateKey[T]) GetOr(r *http.Request, defaultValue T) T {
+	if value, found := k.Get(r); found {
+		return value
+	}
+	return defaultValue
+}
+
+// Delete to remove the value stored for the request.
+func (k StateKey[T]) Delete(r *http.Request) {
+	if state := GetState(r); state != nil {
+		state.delete(k)
+	}
+}
+
+// GetState to get the RequestState of the request. Return nil if the request was not served through NewHandler, NewServeMux or WithState.
+func GetState(r *http.Request) *RequestState {
+	if value, ok := r.Context().Value(requestStateKey{}).(*RequestState); ok {
+		return value
+	}
+	return nil
+}
+
+// WithState to get the request with a new empty RequestState if it does not have one e.g when calling a handler directly in a test.
+func WithState(r *http.Request) *http.Request {
+	if GetState(r) != nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestStateKey{}, &RequestState{value: make(map[interface{}]interface{})}))
+}
+
+// stateMiddleware to create the RequestState if the request does not have one and clear it when the request is served.
+func stateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetState(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = WithState(r)
+		defer GetState(r).clear()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Keys to get the sorted name of every key set, for debugging.
+func (s *RequestState) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var keys []string
+	for key := range s.value {
+		if named, ok := key.(interface{ Name() string }); ok {
+			keys = append(keys, named.Name())
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *RequestState) set(key interface{}, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.value[key] = value
+}
+
+func (s *RequestState) get(key interface{}) (interface{}, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, found := s.value[key]
+	return value, found
+}
+
+func (s *RequestState) delete(key interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.value, key)
+}
+
+func (s *RequestState) clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.value = make(map[interface{}]interface{})
+}
@@ -0,0 +1,90 @@
+package httpUtil
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var stateUserKey = NewStateKey[string]("user")
+var stateCountKey = NewStateKey[int]("count")
+var stateOtherKey = NewStateKey[int]("user")
+
+// stateCount to increment stateCountKey.
+func stateCount(r *http.Request) {
+	stateCountKey.Set(r, stateCountKey.GetOr(r, 0)+1)
+}
+
+func TestRequestStateShared(t *testing.T) {
+	var kept *RequestState
+	handler := newTestHandler(t, newTestConfig(), func() {
+		Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				stateCount(r)
+				next.ServeHTTP(w, r)
+			})
+		})
+		Group("/state").Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				stateCount(r)
+				stateUserKey.Set(r, "tiger")
+				next.ServeHTTP(w, r)
+			})
+		}).AddChainHandler("/chain", []ChainNextHandler{
+			chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				stateCount(r)
+				stateOtherKey.Set(r, 7)
+				return true
+			}),
+			chainNextFunc(func(w http.ResponseWriter, r *http.Request) bool {
+				kept = GetState(r)
+				user, _ := stateUserKey.Get(r)
+				other, _ := stateOtherKey.Get(r)
+				fmt.Fprintf(w, "%s %d %d %v", user, other, stateCountKey.GetOr(r, 0), kept.Keys())
+				return true
+			}),
+		})
+	})
+	if w := serveTest(handler, http.MethodGet, "/state/chain"); w.Body.String() != "tiger 7 3 [count route user user]" {
+		t.Errorf("GET /state/chain = %q, want the state shared by global, group middleware and chain", w.Body.String())
+	}
+	if kept == nil || len(kept.Keys()) != 0 {
+		t.Error("RequestState not cleared once the request is served")
+	}
+	if w := serveTest(handler, http.MethodGet, "/state/chain"); w.Body.String() != "tiger 7 3 [count route user user]" {
+		t.Errorf("second GET /state/chain = %q, want a new state per request", w.Body.String())
+	}
+}
+
+func TestRequestStateWithoutHandler(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	stateUserKey.Set(r, "lost")
+	if _, found := stateUserKey.Get(r); found || GetState(r) != nil {
+		t.Error("value set on a request without RequestState")
+	}
+	r = WithState(r)
+	if WithState(r) != r {
+		t.Error("WithState replaced the existing RequestState")
+	}
+	stateUserKey.Set(r, "tiger")
+	if value := stateUserKey.GetOr(r, "none"); value != "tiger" {
+		t.Errorf("Get = %q, want tiger", value)
+	}
+	if _, found := stateOtherKey.Get(r); found {
+		t.Error("key of another type with the same name found")
+	}
+	stateUserKey.Delete(r)
+	if value := stateUserKey.GetOr(r, "none"); value != "none" {
+		t.Errorf("Get after Delete = %q, want none", value)
+	}
+
+	w := httptest.NewRecorder()
+	stateMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if GetState(req) != GetState(r) {
+			t.Error("stateMiddleware replaced the existing RequestState")
+		}
+		io.WriteString(w, "ok")
+	})).ServeHTTP(w, r)
+}
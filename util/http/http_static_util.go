@@ -0,0 +1,435 @@
+package httpUtil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"tiger/util/log"
+	"tiger/util/template"
+	"time"
+)
+
+// CacheControlImmutable is the Cache-Control value for files whose content never change under the same url e.g fingerprinted files.
+const CacheControlImmutable = "public, max-age=31536000, immutable"
+
+// CacheRule set the Cache-Control response header of the files matching Pattern.
+// Pattern use path.Match syntax against the file path relative to the mount e.g *.css or js/*.js, a Pattern without / is also matched against the file name.
+type CacheRule struct {
+	Pattern      string
+	CacheControl string
+}
+
+// StaticMount is one url prefix serving files from a directory or a fs.FS such as embed.FS. see AddStaticMount
+type StaticMount struct {
+	//url prefix e.g /static/
+	Prefix string
+	//directory on disk. used if FS is nil
+	Dir string
+	//e.g embed.FS. use fs.Sub to serve a sub directory of it
+	FS fs.FS
+	//list directory content when there is no index.html. default false reply not found
+	DirectoryListing bool
+	//first matching rule win. no matching rule send no Cache-Control
+	CacheRule []CacheRule
+	//serve file.br or file.gz instead of file when it exist and the client accept the encoding
+	Precompressed bool
+	//hash every file when mounted and also serve it under the fingerprinted name e.g css/app.css as css/app.3f2a9c1b.css with CacheControlImmutable. see Asset
+	Fingerprint bool
+	//serve the mount index.html for unknown path without file extension under the mount e.g single page application client side routing. missing /app.js is still not found
+	SpaFallback bool
+}
+
+type staticHandler struct {
+	Mount StaticMount
+	Fsys  fs.FS
+	//file name to fingerprinted file name
+	Asset map[string]string
+	//fingerprinted file name to file name
+	AssetOrigin map[string]string
+}
+
+type staticEtag struct {
+	ModTime time.Time
+	Size    int64
+	Etag    string
+}
+
+var mapStaticEtag sync.Map
+var onceStatic sync.Once
+var mutexStatic sync.RWMutex
+var listStaticHandler []*staticHandler
+
+var staticListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Path}}</title></head><body>
+<h1>{{.Path}}</h1>
+<ul>{{range .Entry}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>
+</body></html>`))
+
+// AddStaticMount to serve static files under the url prefix of the default host for GET and HEAD.
+// 	Example
+// 	//go:embed public
+// 	var publicFS embed.FS
+// 	sub, _ := fs.Sub(publicFS, "public")
+// 	AddStaticMount(StaticMount{Prefix: "/assets/", FS: sub, Precompressed: true, CacheRule: []CacheRule{{Pattern: "*.woff2", CacheControl: CacheControlImmutable}}})
+// 	AddStaticMount(StaticMount{Prefix: "/app/", Dir: "web/dist", SpaFallback: true})
+// every file is sent with a strong ETag computed from its content and Range, If-None-Match, If-Modified-Since are supported.
+// with Fingerprint the files are hashed once here so files changed on disk later keep their fingerprint until mounted again.
+func AddStaticMount(mount StaticMount) error {
+	handler, err := newStaticHandler(mount)
+	if err != nil {
+		return err
+	}
+	addStaticHandler(handler)
+	initMapHandler()
+	mutexHttp.Lock()
+	defer mutexHttp.Unlock()
+	addHandlerInternal(&httpVerbHandler{UrlMapping: handler.Mount.Prefix, MatchKind: matchPath, NextHandler: handler}, http.MethodGet, http.MethodHead)
+	return nil
+}
+
+// AddStaticMount is like the package AddStaticMount but bound to the RouteGroup. the group prefix is put in front of the mount prefix.
+func (g *RouteGroup) AddStaticMount(mount StaticMount) error {
+	mount.Prefix = g.urlMapping(mount.Prefix)
+	handler, err := newStaticHandler(mount)
+	if err != nil {
+		return err
+	}
+	addStaticHandler(handler)
+	g.add(&httpVerbHandler{UrlMapping: handler.Mount.Prefix, MatchKind: matchPath, NextHandler: handler}, http.MethodGet, http.MethodHead)
+	return nil
+}
+
+func newStaticHandler(mount StaticMount) (*staticHandler, error) {
+	if !strings.HasPrefix(mount.Prefix, "/") {
+		mount.Prefix = "/" + mount.Prefix
+	}
+	if !strings.HasSuffix(mount.Prefix, "/") {
+		mount.Prefix += "/"
+	}
+	fsys := mount.FS
+	if fsys == nil {
+		if stat, err := os.Stat(mount.Dir); err != nil || !stat.IsDir() {
+			return nil, errors.New("error access static directory " + mount.Dir)
+		}
+		fsys = os.DirFS(mount.Dir)
+	}
+	handler := &staticHandler{Mount: mount, Fsys: fsys}
+	clearStaticEtag(mount.Prefix)
+	if mount.Fingerprint {
+		if err := handler.fingerprint(); err != nil {
+			return nil, err
+		}
+	}
+	return handler, nil
+}
+
+// addStaticHandler to keep the mount for Asset lookup. a mount with the same prefix is replaced.
+func addStaticHandler(handler *staticHandler) {
+	onceStatic.Do(func() { //singleton
+		templateUtil.SetAssetFunc(Asset)
+	})
+	mutexStatic.Lock()
+	defer mutexStatic.Unlock()
+	for index, value := range listStaticHandler {
+		if value.Mount.Prefix == handler.Mount.Prefix {
+			listStaticHandler[index] = handler
+			return
+		}
+	}
+	listStaticHandler = append(listStaticHandler, handler)
+}
+
+// fingerprint to hash every file of the mount except the precompressed siblings and build the fingerprinted names.
+func (a *staticHandler) fingerprint() error {
+	a.Asset = make(map[string]string)
+	a.AssetOrigin = make(map[string]string)
+	return fs.WalkDir(a.Fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || (a.Mount.Precompressed && (strings.HasSuffix(name, ".br") || strings.HasSuffix(name, ".gz"))) {
+			return nil
+		}
+		etag, err := a.etagFile(name)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + strings.Trim(etag, `"`)[:8] + ext
+		a.Asset[name] = fingerprinted
+		a.AssetOrigin[fingerprinted] = name
+		return nil
+	})
+}
+
+// Asset to get the url of a static file served under its fingerprinted name. Also called by the asset function of templateUtil templates e.g {{asset "css/app.css"}}
+// name is either relative to a mount e.g css/app.css where the mounts are searched in the order added, or the full url e.g /static/css/app.css
+// return /static/css/app.3f2a9c1b.css or name as it is if no mount with Fingerprint has the file.
+func Asset(name string) string {
+	mutexStatic.RLock()
+	defer mutexStatic.RUnlock()
+	for _, handler := range listStaticHandler {
+		if strings.HasPrefix(name, handler.Mount.Prefix) {
+			if value, found := handler.Asset[strings.TrimPrefix(name, handler.Mount.Prefix)]; found {
+				return handler.Mount.Prefix + value
+			}
+		}
+	}
+	relative := strings.TrimPrefix(name, "/")
+	for _, handler := range listStaticHandler {
+		if value, found := handler.Asset[relative]; found {
+			return handler.Mount.Prefix + value
+		}
+	}
+	logUtil.DebugPrintln("cannot find fingerprinted asset " + name)
+	return name
+}
+
+// AssetManifest to get the url of every fingerprinted static file to its fingerprinted url e.g /static/css/app.css to /static/css/app.3f2a9c1b.css for external tooling.
+// it is served on /assets.json of the admin listener and exported by running tiger -manifest <file>
+func AssetManifest() map[string]string {
+	mutexStatic.RLock()
+	defer mutexStatic.RUnlock()
+	manifest := make(map[string]string)
+	for _, handler := range listStaticHandler {
+		for key, value := range handler.Asset {
+			manifest[handler.Mount.Prefix+key] = handler.Mount.Prefix + value
+		}
+	}
+	return manifest
+}
+
+// AssetManifestHandler to get a http.Handler serving AssetManifest as json.
+func AssetManifestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.MarshalIndent(AssetManifest(), "", "  ")
+		if err != nil {
+			log.Print(err)
+			Error(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+func (a *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, a.Mount.Prefix)), "/")
+	if name == "" {
+		name = "."
+	}
+	if origin, found := a.AssetOrigin[name]; found {
+		a.serveFile(w, r, origin, CacheControlImmutable)
+		return
+	}
+	stat, err := fs.Stat(a.Fsys, name)
+	if err != nil {
+		if a.Mount.SpaFallback && path.Ext(name) == "" {
+			a.serveFile(w, r, "index.html", "no-cache") //same url serve different page so must not be cached
+			return
+		}
+		NotFound(w, r)
+		return
+	}
+	if stat.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") { //relative url inside the directory need the trailing slash
+			redirectPath(w, r, r.URL.Path+"/")
+			return
+		}
+		index := path.Join(name, "index.html")
+		if _, err := fs.Stat(a.Fsys, index); err == nil {
+			a.serveFile(w, r, index, "")
+			return
+		}
+		if a.Mount.DirectoryListing {
+			a.serveList(w, r, name)
+			return
+		}
+		NotFound(w, r)
+		return
+	}
+	a.serveFile(w, r, name, "")
+}
+
+// serveFile to serve the file or its precompressed sibling. cacheControl "" use the CacheRule of the mount.
+func (a *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, cacheControl string) {
+	serveName, encoding := name, ""
+	if a.Mount.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accept := r.Header.Get("Accept-Encoding")
+		for _, value := range []struct{ Encoding, Ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !acceptEncoding(accept, value.Encoding) {
+				continue
+			}
+			if stat, err := fs.Stat(a.Fsys, name+value.Ext); err == nil && !stat.IsDir() {
+				serveName, encoding = name+value.Ext, value.Encoding
+				break
+			}
+		}
+	}
+
+	f, err := a.Fsys.Open(serveName)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		NotFound(w, r)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			Error(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	etag, err := a.etag(serveName, stat, content)
+	if err != nil {
+		log.Print(err)
+	} else {
+		w.Header().Set("ETag", etag)
+	}
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if cacheControl == "" {
+		cacheControl = a.cacheControl(name)
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	http.ServeContent(w, r, path.Base(name), stat.ModTime(), content)
+}
+
+// etagFile to get the strong ETag of the file by name.
+func (a *staticHandler) etagFile(name string) (string, error) {
+	f, err := a.Fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		content = bytes.NewReader(b)
+	}
+	return a.etag(name, stat, content)
+}
+
+// etag to get the strong ETag of the file content. it is cached until the file modification time or size change.
+func (a *staticHandler) etag(name string, stat fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := a.Mount.Prefix + "\x00" + name
+	if value, found := mapStaticEtag.Load(key); found {
+		cached := value.(*staticEtag)
+		if cached.ModTime.Equal(stat.ModTime()) && cached.Size == stat.Size() {
+			return cached.Etag, nil
+		}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+	mapStaticEtag.Store(key, &staticEtag{ModTime: stat.ModTime(), Size: stat.Size(), Etag: etag})
+	return etag, nil
+}
+
+// clearStaticEtag to forget the ETag of the files of the prefix mounted before. embed.FS has no modification time so a file of the same size would keep its old ETag.
+func clearStaticEtag(prefix string) {
+	mapStaticEtag.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix+"\x00") {
+			mapStaticEtag.Delete(key)
+		}
+		return true
+	})
+}
+
+func (a *staticHandler) cacheControl(name string) string {
+	for _, rule := range a.Mount.CacheRule {
+		if found, _ := path.Match(rule.Pattern, name); found {
+			return rule.CacheControl
+		}
+		if !strings.Contains(rule.Pattern, "/") {
+			if found, _ := path.Match(rule.Pattern, path.Base(name)); found {
+				return rule.CacheControl
+			}
+		}
+	}
+	return ""
+}
+
+func (a *staticHandler) serveList(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(a.Fsys, name)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+	var entry []string
+	for _, value := range entries {
+		if value.IsDir() {
+			entry = append(entry, value.Name()+"/")
+		} else {
+			entry = append(entry, value.Name())
+		}
+	}
+	sort.Strings(entry)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := staticListTemplate.Execute(w, map[string]interface{}{"Path": r.URL.Path, "Entry": entry}); err != nil {
+		log.Print(err)
+	}
+}
+
+// acceptEncoding to check if the Accept-Encoding header allow the encoding with a quality above 0.
+func acceptEncoding(header string, encoding string) bool {
+	for _, value := range strings.Split(header, ",") {
+		part := strings.Split(strings.TrimSpace(value), ";")
+		if !strings.EqualFold(strings.TrimSpace(part[0]), encoding) {
+			continue
+		}
+		for _, param := range part[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || param == "q=0.0" || param == "q=0.00" || param == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func staticMountPrefix(staticFilePath string) string {
+	return "/" + filepath.Base(staticFilePath) + "/"
+}
@@ -0,0 +1,217 @@
+package httpUtil
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"tiger/util/template"
+)
+
+//go:embed testdata/static
+var staticTestFS embed.FS
+
+// staticTestSub to get the testdata/static directory of staticTestFS.
+func staticTestSub(t *testing.T) fs.FS {
+	t.Helper()
+	sub, err := fs.Sub(staticTestFS, "testdata/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func serveHeaderTest(handler http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticMount(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "robots.txt"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	handler := newTestHandler(t, newTestConfig(), func() {
+		if err := AddStaticMount(StaticMount{Prefix: "/disk", Dir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddStaticMount(StaticMount{Prefix: "/embed/", FS: staticTestSub(t)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddStaticMount(StaticMount{Prefix: "/list/", FS: staticTestSub(t), DirectoryListing: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := AddStaticMount(StaticMount{Prefix: "/missing/", Dir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("AddStaticMount of a missing directory accepted")
+	}
+	for target, want := range map[string]string{
+		"/disk/robots.txt":      "disk",
+		"/embed/docs/guide.txt": "guide\n",
+		"/embed/":               "<!DOCTYPE html><title>app</title>\n",
+	} {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want 200 %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+	if w := serveTest(handler, http.MethodGet, "/embed/css/app.css"); w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Errorf("GET /embed/css/app.css Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+	if w := serveTest(handler, http.MethodPost, "/embed/docs/guide.txt"); w.Code != http.StatusNotFound {
+		t.Errorf("POST /embed/docs/guide.txt = %d, want 404", w.Code)
+	}
+	for target, code := range map[string]int{"/disk/empty/": http.StatusNotFound, "/embed/docs/": http.StatusNotFound, "/embed/docs": http.StatusMovedPermanently, "/list/docs/": http.StatusOK} {
+		if w := serveTest(handler, http.MethodGet, target); w.Code != code {
+			t.Errorf("GET %s = %d, want %d", target, w.Code, code)
+		}
+	}
+	if w := serveTest(handler, http.MethodGet, "/list/docs/"); !strings.Contains(w.Body.String(), `<a href="guide.txt">`) {
+		t.Errorf("GET /list/docs/ does not list guide.txt: %s", w.Body.String())
+	}
+}
+
+func TestStaticCacheEtag(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddStaticMount(StaticMount{Prefix: "/cache/", FS: staticTestSub(t), CacheRule: []CacheRule{
+			{Pattern: "js/*.js", CacheControl: "public, max-age=60"},
+			{Pattern: "*.css", CacheControl: CacheControlImmutable},
+		}})
+	})
+	for target, want := range map[string]string{"/cache/js/app.js": "public, max-age=60", "/cache/css/app.css": CacheControlImmutable, "/cache/docs/guide.txt": ""} {
+		if w := serveTest(handler, http.MethodGet, target); w.Header().Get("Cache-Control") != want {
+			t.Errorf("GET %s Cache-Control = %q, want %q", target, w.Header().Get("Cache-Control"), want)
+		}
+	}
+	etag := serveTest(handler, http.MethodGet, "/cache/css/app.css").Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/`) {
+		t.Fatalf("ETag = %q, want a strong ETag", etag)
+	}
+	if other := serveTest(handler, http.MethodGet, "/cache/js/app.js").Header().Get("ETag"); other == etag {
+		t.Error("same ETag for files of different content")
+	}
+	if w := serveHeaderTest(handler, "/cache/css/app.css", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("GET with If-None-Match = %d, want 304 without body", w.Code)
+	}
+	if w := serveHeaderTest(handler, "/cache/css/app.css", map[string]string{"If-None-Match": `"other"`}); w.Code != http.StatusOK {
+		t.Errorf("GET with another If-None-Match = %d, want 200", w.Code)
+	}
+	if w := serveHeaderTest(handler, "/cache/css/app.css", map[string]string{"Range": "bytes=0-3"}); w.Code != http.StatusPartialContent || w.Body.String() != "body" {
+		t.Errorf("GET with Range = %d %q, want 206 body", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddStaticMount(StaticMount{Prefix: "/compress/", FS: staticTestSub(t), Precompressed: true})
+	})
+	for accept, want := range map[string][2]string{
+		"":                  {"", "body{color:red}\n"},
+		"gzip":              {"gzip", "gzip css\n"},
+		"gzip, br":          {"br", "brotli css\n"},
+		"br;q=0, gzip":      {"gzip", "gzip css\n"},
+		"deflate, identity": {"", "body{color:red}\n"},
+	} {
+		w := serveHeaderTest(handler, "/compress/css/app.css", map[string]string{"Accept-Encoding": accept})
+		if w.Header().Get("Content-Encoding") != want[0] || w.Body.String() != want[1] {
+			t.Errorf("Accept-Encoding %q = %q %q, want %q %q", accept, w.Header().Get("Content-Encoding"), w.Body.String(), want[0], want[1])
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+			t.Errorf("Accept-Encoding %q Vary %q Content-Type %q, want Accept-Encoding and the type of app.css", accept, w.Header().Get("Vary"), w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestStaticSpaFallback(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddStaticMount(StaticMount{Prefix: "/spa/", FS: staticTestSub(t), SpaFallback: true})
+	})
+	w := serveTest(handler, http.MethodGet, "/spa/user/12/edit")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<title>app</title>") || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("GET /spa/user/12/edit = %d %q %q, want index.html not cached", w.Code, w.Header().Get("Cache-Control"), w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/spa/missing.js"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /spa/missing.js = %d, want 404", w.Code)
+	}
+	if w := serveTest(handler, http.MethodGet, "/spa/js/app.js"); w.Body.String() != "console.log(1)\n" {
+		t.Errorf("GET /spa/js/app.js = %q, want the file", w.Body.String())
+	}
+}
+
+func TestAsset(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		if err := AddStaticMount(StaticMount{Prefix: "/asset/", FS: staticTestSub(t), Precompressed: true, Fingerprint: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	url := Asset("css/app.css")
+	if !strings.HasPrefix(url, "/asset/css/app.") || !strings.HasSuffix(url, ".css") || url == "/asset/css/app.css" {
+		t.Fatalf("Asset(css/app.css) = %q, want a fingerprinted url under /asset/", url)
+	}
+	if full := Asset("/asset/css/app.css"); full != url {
+		t.Errorf("Asset(/asset/css/app.css) = %q, want %q", full, url)
+	}
+	if missing := Asset("css/missing.css"); missing != "css/missing.css" {
+		t.Errorf("Asset(css/missing.css) = %q, want the name as it is", missing)
+	}
+	manifest := AssetManifest()
+	if manifest["/asset/css/app.css"] != url {
+		t.Errorf("AssetManifest()[/asset/css/app.css] = %q, want %q", manifest["/asset/css/app.css"], url)
+	}
+	if _, found := manifest["/asset/css/app.css.gz"]; found {
+		t.Error("AssetManifest() has the precompressed sibling")
+	}
+
+	file := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(file, []byte(`<link href="{{asset "css/app.css"}}">`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tpl, err := templateUtil.AddTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<link href="` + url + `">`; b.String() != want {
+		t.Fatalf("template = %q, want %q", b.String(), want)
+	}
+
+	w := serveHeaderTest(handler, url, map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != http.StatusOK || w.Body.String() != "gzip css\n" || w.Header().Get("Cache-Control") != CacheControlImmutable {
+		t.Errorf("GET %s = %d %q %q, want the precompressed file with immutable caching", url, w.Code, w.Header().Get("Cache-Control"), w.Body.String())
+	}
+	if w := serveTest(handler, http.MethodGet, "/asset/css/app.css"); w.Code != http.StatusOK || w.Header().Get("Cache-Control") == CacheControlImmutable {
+		t.Errorf("GET /asset/css/app.css = %d %q, want the file without immutable caching", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestStaticRemount(t *testing.T) {
+	handler := newTestHandler(t, newTestConfig(), func() {
+		AddStaticMount(StaticMount{Prefix: "/re/", FS: fstest.MapFS{"app.js": {Data: []byte("one")}}, Fingerprint: true})
+	})
+	etag, url := serveTest(handler, http.MethodGet, "/re/app.js").Header().Get("ETag"), Asset("/re/app.js")
+	if err := AddStaticMount(StaticMount{Prefix: "/re/", FS: fstest.MapFS{"app.js": {Data: []byte("two")}}, Fingerprint: true}); err != nil {
+		t.Fatal(err)
+	}
+	w := serveTest(handler, http.MethodGet, "/re/app.js")
+	if w.Body.String() != "two" || w.Header().Get("ETag") == etag {
+		t.Errorf("GET /re/app.js after mount again = %q ETag %s, want the new content with another ETag than %s", w.Body.String(), w.Header().Get("ETag"), etag)
+	}
+	if Asset("/re/app.js") == url {
+		t.Errorf("Asset(/re/app.js) = %q, want a new fingerprint after mount again", url)
+	}
+}
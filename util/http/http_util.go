@@ -1,15 +1,41 @@
 // httpUtil is the main package containing all http server related features for application usage.
 //
 // 	http_error_util.go
-// 	Above package is for application to register their own custom http error code webpages. Optional.
+// 	http_handler_error_util.go
+// 	http_recover_util.go
+// 	http_problem_util.go
+// 	http_report_util.go
+// 	Above packages are for application to register their own custom http error code webpages and return error from handlers. Optional.
 //
-// 	http_rewrite.go
-// 	Above package is for application to enable url rewrite on the same http server. Optional
+// 	http_rewrite_util.go
+// 	http_rewrite_engine_util.go
+// 	http_rewrite_file_util.go
+// 	Above packages are for application to enable url rewrite on the same http server. Optional
 //
 // 	http_util.go
 // 	http_chain_util.go
+// 	http_middleware_util.go
+// 	http_fanout_util.go
+// 	http_param_util.go
+// 	http_context_util.go
+// 	http_state_util.go
+// 	http_route_util.go
 // 	Above packages are for application to register their url and handler either as a single or a chain of handlers. Mandatory.
 //
+// 	http_static_util.go
+// 	Above package is for application to serve static files from directories or embed.FS under url prefixes. Optional.
+//
+// 	http_proxy_util.go
+// 	Above package is for application to forward url prefixes to a pool of upstream servers. Optional.
+//
+// 	http_group_util.go
+// 	http_host_util.go
+// 	Above packages are for application to bind url mapping, custom error pages and url rewrite to a host pattern or url prefix. Optional.
+//
+// 	http_admin_util.go
+// 	http_openapi_util.go
+// 	Above packages are for the admin listener set by AdminAddr in config.json to list and match url mapping and generate the OpenAPI document. Optional.
+//
 // 	handler_util.go
 // 	Above file is the ENTRY POINT called by tiger framework for all application to add in their own application specific code. Functions inside this file act as placeholder for application to add. The keyword ENTRY POINT will be stated explicitly in the function documentation so take note.
 package httpUtil
@@ -20,7 +46,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -30,43 +55,75 @@ import (
 )
 
 // PathTokenHandler is the interface for application to implement for Path Param url feature. Framework will process and pass in the placeholder values inside the pathParam map.
+// new code can implement http.Handler, call PathParam(r, name) and register it with AddHandlerPlaceholder instead.
 type PathTokenHandler interface {
 	ServeHTTP(w http.ResponseWriter, r *http.Request, pathParam map[string]string)
 }
 
+type pathTokenAdapter struct {
+	PathTokenHandler PathTokenHandler
+}
+
+func (a *pathTokenAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.PathTokenHandler.ServeHTTP(w, r, PathParams(r))
+}
+
+// PathTokenAdapter to turn a PathTokenHandler into a http.Handler. The pathParam map is taken from the request context.
+func PathTokenAdapter(pathTokenHandler PathTokenHandler) http.Handler {
+	return &pathTokenAdapter{PathTokenHandler: pathTokenHandler}
+}
+
+const (
+	matchPath      = "path"
+	matchPathParam = "path-param"
+	matchRegEx     = "regex"
+)
+
 type httpVerbHandler struct {
 	HttpVerb    []string
+	Host        string
+	UrlMapping  string
+	MatchKind   string
+	Name        string
+	Doc         *RouteDoc
 	NextHandler http.Handler
 	RegEx       *regexp.Regexp
+	RegExFold   *regexp.Regexp
 
 	//below to handle path param
-	PathToken        []string
-	PathTokenHandler *PathTokenHandler
+	PathToken []*pathParamToken
 
 	//below to handle ChainHandler*
-	ChainNextHandler      []ChainNextHandler
-	ChainPathTokenHandler []ChainPathTokenHandler
+	ChainNextHandler []ChainNextHandler
+
+	//below to handle Middleware of RouteGroup
+	Middleware []Middleware
+	//NextHandler or ChainNextHandler wrapped by Middleware. set by compose
+	Serve http.Handler
 }
 
 func (a *httpVerbHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	httpVerbFound := httpVerbOk(r, a.HttpVerb)
-	if httpVerbFound {
-		if a.NextHandler != nil {
-			a.NextHandler.ServeHTTP(w, r)
-		} else if a.ChainNextHandler != nil {
-			for _, value := range a.ChainNextHandler {
-				if ok := value.ServeNextHTTP(w, r); !ok {
-					break
-				}
-			}
-		} else {
-			NotFound(w, r)
-		}
+	if httpVerbFound && a.Serve != nil {
+		a.Serve.ServeHTTP(w, r)
 	} else {
 		NotFound(w, r)
 	}
 }
 
+// compose to build Serve from NextHandler or ChainNextHandler and Middleware. must be called again whenever they change.
+func (a *httpVerbHandler) compose() {
+	a.Serve = nil
+	if a.NextHandler != nil {
+		a.Serve = a.NextHandler
+	} else if a.ChainNextHandler != nil {
+		a.Serve = composeChain(a.ChainNextHandler)
+	}
+	if a.Serve != nil {
+		a.Serve = WrapMiddleware(a.Serve, a.Middleware...)
+	}
+}
+
 var validHttpVerb = map[string]bool{
 	http.MethodGet:     true,
 	http.MethodHead:    true,
@@ -81,11 +138,13 @@ var validHttpVerb = map[string]bool{
 
 var mutexHttp sync.RWMutex
 var mux *http.ServeMux
+var rootHandler http.Handler
 var onceHttp sync.Once
 var onceHandler sync.Once
 var mapHandler map[string]http.Handler
 var mapHandlerRegEx map[string]http.Handler
 var mapHandlerPathParam map[string]http.Handler
+var mapHostRouteTable map[string]*routeTable
 var pathParamRE *regexp.Regexp
 var pathParamSlashRE *regexp.Regexp
 
@@ -94,22 +153,93 @@ func NewServeMux(c *config.Config, db *sql.DB) *http.ServeMux {
 	onceHttp.Do(func() { //singleton
 		logUtil.DebugPrint("serve mux first time init\n")
 		initMapHandler()
+		setPathPolicy(c)
 		mux = http.NewServeMux()
 		setupRootHandler(c, db, mux)
 		setupStaticPath(c, db, mux)
-		for key, value := range mapHandler {
-			mux.Handle(key, value)
-		}
+		mutexHttp.Lock()
+		routeTableBatch = false
+		publishRouteTable()
+		mutexHttp.Unlock()
 	})
 	return mux
 }
 
+// NewHandler to get the http.Handler of the http server. it is the root handler of the http.ServeMux from NewServeMux wrapped by RewriteUrlHandler, CleanPathHandler and the global middleware added by Use.
+// the http.ServeMux itself is not used as it clean the url path with 301 for every http verb, so the url path is only cleaned when the json attribute CleanPath is true in config.json.
+func NewHandler(c *config.Config, db *sql.DB) http.Handler {
+	NewServeMux(c, db)
+	return setMiddlewareBase(CleanPathHandler(c, RewriteUrlHandler(c, rootHandler)))
+}
+
+// Reset to clear every url mapping, host pattern, custom error page, rewrite url, static mount, proxy route and global middleware so the next NewServeMux and NewAdminServeMux build new ones.
+// it is meant for tests to start each test from an empty state, see package tigertest. must not be called while requests are being served.
+func Reset() {
+	mutexHttp.Lock()
+	mux, rootHandler = nil, nil
+	onceHttp = sync.Once{}
+	onceHandler = sync.Once{}
+	mapHandler, mapHandlerRegEx, mapHandlerPathParam, mapHostRouteTable = nil, nil, nil, nil
+	currentPathPolicy = pathPolicy{TrailingSlash: TrailingSlashStrict}
+	mutexHttp.Unlock()
+
+	mutexHttpError.Lock()
+	onceCustomHttpError = sync.Once{}
+	mapCustomHttpError, mapHostCustomHttpError = nil, nil
+	errorTextFunc, errorTextDefaultTag = nil, ""
+	mutexHttpError.Unlock()
+
+	mutexProblem.Lock()
+	listProblemRule = nil
+	mutexProblem.Unlock()
+
+	mutexReport.Lock()
+	listErrorReporter, mapErrorStat = nil, nil
+	errorReportConfig = ErrorReportConfig{}
+	mutexReport.Unlock()
+
+	mutexRewriteUrl.Lock()
+	onceRewriteUrl = sync.Once{}
+	defaultRewriteEngine, mapHostRewriteEngine, mapFileRewriteEngine = nil, nil, nil
+	if stopRewriteFile != nil {
+		close(stopRewriteFile)
+		stopRewriteFile = nil
+	}
+	onceRewriteFile = sync.Once{}
+	rewriteResultCache.reset(-1)
+	mutexRewriteUrl.Unlock()
+
+	mutexHost.Lock()
+	onceHost = sync.Once{}
+	mapHostPattern, listHostPattern = nil, nil
+	mutexHost.Unlock()
+
+	mutexStatic.Lock()
+	listStaticHandler = nil
+	mapStaticEtag = sync.Map{}
+	mutexStatic.Unlock()
+
+	stopProxyHandler()
+
+	mutexMiddleware.Lock()
+	listMiddleware, middlewareBase = nil, nil
+	mutexMiddleware.Unlock()
+
+	onceAdmin = sync.Once{}
+	onceAdminMux = sync.Once{}
+	adminMux = nil
+	initMapHandler()
+}
+
 func initMapHandler() {
 	onceHandler.Do(func() { //singleton
 		mapHandler = make(map[string]http.Handler)
 		mapHandlerRegEx = make(map[string]http.Handler)
 		mapHandlerPathParam = make(map[string]http.Handler)
-		pathParamRE, _ = regexp.Compile(`{\s*(\w+)\s*}|:\s*(\w+)`)
+		mapHostRouteTable = make(map[string]*routeTable)
+		routeTableChanged, routeTableBatch = nil, true
+		publishRouteTable()
+		pathParamRE, _ = regexp.Compile(`^{\s*(\*?\w+)\s*(?::\s*(.+?)\s*)?}$|^:\s*(\w+)$`)
 		pathParamSlashRE, _ = regexp.Compile("/+")
 	})
 }
@@ -128,7 +258,8 @@ func httpVerbOk(r *http.Request, httpVerb []string) bool {
 func getHandlerRe(urlMapping string) *regexp.Regexp {
 	var re *regexp.Regexp
 	var err error
-	if re, err = regexp.Compile("(?i)" + urlMapping); err != nil { //ignore case
+	if re, err = regexp.Compile(urlMapping); err != nil {
+		log.Print(err)
 		return nil
 	}
 	return re
@@ -150,7 +281,7 @@ func AddHandler(urlMapping string, handler http.Handler, httpVerb ...string) {
 	initMapHandler()
 	mutexHttp.Lock()
 	defer mutexHttp.Unlock()
-	addHandlerInternal(urlMapping, handler, nil, nil, nil, httpVerb...)
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPath, NextHandler: handler}, httpVerb...)
 }
 
 // AddHandlerRegEx to add url mapping to handler. Regular expression url syntax.
@@ -162,163 +293,246 @@ func AddHandlerRegEx(urlMapping string, handler http.Handler, httpVerb ...string
 	if re == nil {
 		return
 	}
-	addHandlerInternal(urlMapping, handler, nil, nil, re, httpVerb...)
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchRegEx, NextHandler: handler, RegEx: re}, httpVerb...)
 }
 
 // AddHandlerPathParam to add url mapping to handler. Placeholder syntax supported are {} and :
 // 	Example {id} or :id
+// a constraint can be placed after the name inside {} and a request that does not satisfy it will fall through to other url mapping.
+// named constraints are int, uint, alpha, alnum, uuid and anything else is a regular expression for the whole path segment.
+// 	Example {id:int} or {uuid:uuid} or {slug:[a-z-]+}
+// a trailing catch-all placeholder {*name} capture all the remaining path segments.
+// 	Example /files/{*path}
+// the placeholder values are passed in the pathParam map. to register a http.Handler reading them by PathParam(r, name) call AddHandlerPlaceholder.
 func AddHandlerPathParam(urlMapping string, pathTokenHandler PathTokenHandler, httpVerb ...string) {
+	AddHandlerPlaceholder(urlMapping, PathTokenAdapter(pathTokenHandler), httpVerb...)
+}
+
+// AddHandlerPlaceholder to add url mapping to handler. Placeholder syntax supported are the same as AddHandlerPathParam
+// 	Example {id} or :id or {id:int} or {*path}
+// the placeholder values are stored in the request context so handler can be any http.Handler and call PathParam(r, name) to read them.
+func AddHandlerPlaceholder(urlMapping string, handler http.Handler, httpVerb ...string) {
 	initMapHandler()
 	mutexHttp.Lock()
 	defer mutexHttp.Unlock()
-	pathToken := splitBySlashToken(urlMapping)
-	addHandlerInternal(urlMapping, nil, &pathTokenHandler, pathToken, nil, httpVerb...)
+	pathToken, err := parsePathParamToken(urlMapping)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	addHandlerInternal(&httpVerbHandler{UrlMapping: urlMapping, MatchKind: matchPathParam, NextHandler: handler, PathToken: pathToken}, httpVerb...)
 }
 
-func addHandlerInternal(urlMapping string, handler http.Handler, pathTokenHandler *PathTokenHandler, pathToken []string, re *regexp.Regexp, httpVerb ...string) {
-	if len(httpVerb) == 0 { //default to http.MethodGet
-		if re == nil && pathTokenHandler == nil {
-			mapHandler[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, NextHandler: handler}
-		} else if pathTokenHandler != nil {
-			mapHandlerPathParam[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, PathTokenHandler: pathTokenHandler, PathToken: pathToken}
-		} else if re != nil {
-			mapHandlerRegEx[urlMapping] = &httpVerbHandler{HttpVerb: []string{http.MethodGet}, NextHandler: handler, RegEx: re}
+// getRouteTable to get the url mapping of the host pattern. host "" is the default host used when no host pattern match.
+func getRouteTable(host string, create bool) *routeTable {
+	if host == "" {
+		return &routeTable{MapHandler: mapHandler, MapHandlerRegEx: mapHandlerRegEx, MapHandlerPathParam: mapHandlerPathParam}
+	}
+	table, found := mapHostRouteTable[host]
+	if !found && create {
+		table = &routeTable{
+			MapHandler:          make(map[string]http.Handler),
+			MapHandlerRegEx:     make(map[string]http.Handler),
+			MapHandlerPathParam: make(map[string]http.Handler),
 		}
-		return
+		mapHostRouteTable[host] = table
 	}
+	return table
+}
 
-	var verbs []string
-	for _, verb := range httpVerb {
-		if validHttpVerb[verb] {
-			verbs = append(verbs, verb)
+func addHandlerInternal(handler *httpVerbHandler, httpVerb ...string) {
+	addHostHandlerInternal("", handler, httpVerb...)
+}
+
+func addHostHandlerInternal(host string, handler *httpVerbHandler, httpVerb ...string) {
+	if len(httpVerb) == 0 { //default to http.MethodGet
+		handler.HttpVerb = []string{http.MethodGet}
+	} else {
+		for _, verb := range httpVerb {
+			if validHttpVerb[verb] {
+				handler.HttpVerb = append(handler.HttpVerb, verb)
+			}
 		}
-	}
-	if len(verbs) != 0 {
-		if re == nil && pathTokenHandler == nil {
-			mapHandler[urlMapping] = &httpVerbHandler{HttpVerb: verbs, NextHandler: handler}
-		} else if pathTokenHandler != nil {
-			mapHandlerPathParam[urlMapping] = &httpVerbHandler{HttpVerb: verbs, PathTokenHandler: pathTokenHandler, PathToken: pathToken}
-		} else if re != nil {
-			mapHandlerRegEx[urlMapping] = &httpVerbHandler{HttpVerb: verbs, NextHandler: handler, RegEx: re}
+		if len(handler.HttpVerb) == 0 {
+			return
 		}
 	}
+
+	handler.Host = host
+	if handler.RegEx != nil { //ignore case version for CaseInsensitive in config.json
+		handler.RegExFold = regexp.MustCompile("(?i)" + handler.RegEx.String())
+	}
+	handler.compose()
+	getRouteTable(host, true).put(handler)
+	changeRouteTable(host)
 }
 
-func handleUrlPathEx(c *config.Config, db *sql.DB, mux *http.ServeMux, w http.ResponseWriter, r *http.Request) error {
-	for key, value := range mapHandler {
-		if found, _ := filepath.Match(key, r.URL.Path); found {
-			logUtil.DebugPrintln("call match path url " + key)
-			value.ServeHTTP(w, r)
-			return nil
+func handleUrlPathEx(table *routeTable, urlPath string, fold bool) (http.Handler, string, error) {
+	if value, found := table.MapHandler[urlPath]; found { //direct match
+		return value, urlPath, nil
+	}
+	if fold {
+		if key, found := table.MapHandlerFold[strings.ToLower(urlPath)]; found {
+			return table.MapHandler[key], key, nil
+		}
+		urlPath = strings.ToLower(urlPath)
+	}
+	var subtree string
+	for key := range table.MapHandler { //longest subtree match e.g /static/ like http.ServeMux
+		matchKey := key
+		if fold {
+			matchKey = strings.ToLower(key)
+		}
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(urlPath, matchKey) && len(key) > len(subtree) {
+			subtree = key
 		}
 	}
-	return errors.New("cannot find match path url " + r.URL.Path)
+	if subtree != "" {
+		return table.MapHandler[subtree], subtree, nil
+	}
+	for key, value := range table.MapHandler {
+		matchKey := key
+		if fold {
+			matchKey = strings.ToLower(key)
+		}
+		if found, _ := filepath.Match(matchKey, urlPath); found {
+			return value, key, nil
+		}
+	}
+	return nil, "", errors.New("cannot find match path url " + urlPath)
 }
 
-func handleUrlRegEx(c *config.Config, db *sql.DB, mux *http.ServeMux, w http.ResponseWriter, r *http.Request) error {
-	for key, value := range mapHandlerRegEx {
+func handleUrlRegEx(table *routeTable, urlPath string, fold bool) (http.Handler, string, error) {
+	for key, value := range table.MapHandlerRegEx {
 		if handler, found := value.(*httpVerbHandler); found {
-			if handler.RegEx.MatchString(r.URL.Path) {
-				logUtil.DebugPrintln("call match regex url " + key)
-				value.ServeHTTP(w, r)
-				return nil
+			re := handler.RegEx
+			if fold {
+				re = handler.RegExFold
+			}
+			if re.MatchString(urlPath) {
+				return value, key, nil
 			}
 		}
 	}
-	return errors.New("cannot find match regex url " + r.URL.Path)
+	return nil, "", errors.New("cannot find match regex url " + urlPath)
 }
 
-func handleUrlPathParam(c *config.Config, db *sql.DB, mux *http.ServeMux, w http.ResponseWriter, r *http.Request) error {
-	actualToken := splitBySlashToken(r.URL.Path)
+func handleUrlPathParam(table *routeTable, urlPath string, fold bool) (http.Handler, string, map[string]string, error) {
+	actualToken := splitBySlashToken(urlPath)
 
-	for key, value := range mapHandlerPathParam {
-		if handler, found := value.(*httpVerbHandler); found {
-			var found bool = false
-			var pathParam = make(map[string]string)
-			if len(handler.PathToken) == len(actualToken) {
-				for index, value := range handler.PathToken {
-					if matched := pathParamRE.MatchString(value); matched {
-						value1 := pathParamRE.ReplaceAllString(value, "$1$2")
-						pathParam[value1] = actualToken[index]
-						found = true
-					} else {
-						if value != actualToken[index] {
-							found = false
-							break
-						}
-					}
-				}
-			}
-			if found {
-				logUtil.DebugPrintln("call match path param url " + key)
-				httpVerbFound := httpVerbOk(r, handler.HttpVerb)
-				if httpVerbFound {
-					if handler.PathTokenHandler != nil {
-						(*handler.PathTokenHandler).ServeHTTP(w, r, pathParam)
-					} else if handler.ChainPathTokenHandler != nil {
-						for _, value := range handler.ChainPathTokenHandler {
-							if ok := value.ServeNextHTTP(w, r, pathParam); !ok {
-								break
-							}
-						}
-					} else {
-						NotFound(w, r)
-					}
-				} else {
-					NotFound(w, r)
-				}
-				return nil
-			}
+	for _, handler := range table.ListPathParam { //most specific first
+		key := handler.UrlMapping
+		pathParam, found := matchPathParamToken(handler.PathToken, actualToken, fold)
+		if !found {
+			continue
+		}
+		//trailing slash must be the same unless absorbed by a catch-all placeholder
+		if hasTrailingSlash(key) != hasTrailingSlash(urlPath) && !(len(handler.PathToken) > 0 && handler.PathToken[len(handler.PathToken)-1].CatchAll) {
+			continue
 		}
+		return handler, key, pathParam, nil
 	}
-	return errors.New("cannot find match path param url " + r.URL.Path)
+	return nil, "", nil, errors.New("cannot find match path param url " + urlPath)
 }
 
-func handleUrl(c *config.Config, db *sql.DB, mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
-	var err error
+// findHandler to look for the handler of urlPath inside one route table.
+func findHandler(table *routeTable, urlPath string, fold bool) (http.Handler, string, map[string]string, bool) {
 	//first try the path expression syntax
-	err = handleUrlPathEx(c, db, mux, w, r)
+	handler, key, err := handleUrlPathEx(table, urlPath, fold)
 	if err == nil {
-		return
+		logUtil.DebugPrintln("call match path url " + key)
+		return handler, key, nil, true
 	}
 	logUtil.DebugPrintln(err.Error())
 
 	//second try the path param syntax
-	err = handleUrlPathParam(c, db, mux, w, r)
+	var pathParam map[string]string
+	handler, key, pathParam, err = handleUrlPathParam(table, urlPath, fold)
 	if err == nil {
-		return
+		logUtil.DebugPrintln("call match path param url " + key)
+		return handler, key, pathParam, true
 	}
 	logUtil.DebugPrintln(err.Error())
 
 	//third try the regular expression syntax
-	err = handleUrlRegEx(c, db, mux, w, r)
-	if err != nil {
-		logUtil.DebugPrintln(err.Error())
-		NotFound(w, r)
+	handler, key, err = handleUrlRegEx(table, urlPath, fold)
+	if err == nil {
+		logUtil.DebugPrintln("call match regex url " + key)
+		return handler, key, nil, true
+	}
+	logUtil.DebugPrintln(err.Error())
+	return nil, "", nil, false
+}
+
+// lookupHandler to look for the handler of host and urlPath. route tables of matching host patterns are tried first, most specific first, then the default host.
+// host placeholder values e.g {tenant}.example.com are merged with the path placeholder values.
+func lookupHandler(snapshot *routeSnapshot, host string, urlPath string) (http.Handler, string, map[string]string, bool) {
+	fold := snapshot.Policy.CaseInsensitive
+	for _, hm := range matchSnapshotHost(snapshot, host) {
+		table, found := snapshot.Host[hm.Pattern]
+		if !found {
+			continue
+		}
+		if handler, key, pathParam, found := findHandler(table, urlPath, fold); found {
+			for name, value := range pathParam {
+				hm.HostParam[name] = value
+			}
+			return handler, key, hm.HostParam, true
+		}
 	}
+	return findHandler(snapshot.Default, urlPath, fold)
+}
+
+// resolveHandler to look for the handler of the request applying the TrailingSlash policy in config.json. redirect is the url path to redirect to if not "".
+func resolveHandler(r *http.Request) (http.Handler, string, map[string]string, string, bool) {
+	snapshot := loadRouteTable()
+	handler, key, pathParam, found := lookupHandler(snapshot, r.Host, r.URL.Path)
+	if found || snapshot.Policy.TrailingSlash == TrailingSlashStrict || r.URL.Path == "/" {
+		return handler, key, pathParam, "", found
+	}
+	toggled := toggleTrailingSlash(r.URL.Path)
+	handler, key, pathParam, found = lookupHandler(snapshot, r.Host, toggled)
+	if found && snapshot.Policy.TrailingSlash == TrailingSlashRedirect {
+		return handler, key, pathParam, toggled, true
+	}
+	return handler, key, pathParam, "", found
+}
+
+func handleUrl(c *config.Config, db *sql.DB, mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
+	handler, key, pathParam, redirect, found := resolveHandler(r)
+	if redirect != "" {
+		logUtil.DebugPrintln("redirect trailing slash " + r.URL.Path + " to " + redirect)
+		redirectPath(w, r, redirect)
+		return
+	}
+	if found {
+		if GetState(r) != nil { //for RoutePattern in middleware outside the url mapping
+			routePatternKey.Set(r, key)
+		}
+		handler.ServeHTTP(w, withRouteInfo(r, key, pathParam))
+		return
+	}
+	if r.URL.Path == "/" {
+		io.WriteString(w, "I am alive!")
+		return
+	}
+	NotFound(w, r)
 }
 
 func setupRootHandler(c *config.Config, db *sql.DB, mux *http.ServeMux) {
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	rootHandler = stateMiddleware(Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Server", c.Site.Name)
-		if r.URL.Path == "/" {
-			io.WriteString(w, "I am alive!")
-		} else {
-			handleUrl(c, db, mux, w, r)
-		}
-	})
+		handleUrl(c, db, mux, w, r)
+	})))
+	mux.Handle("/", rootHandler)
 }
 
+// setupStaticPath to mount the StaticFilePath in config.json under /<last element of StaticFilePath>/ with Fingerprint.
 func setupStaticPath(c *config.Config, db *sql.DB, mux *http.ServeMux) {
-	if stat, err := os.Stat(c.Site.StaticFilePath); err == nil && stat.IsDir() {
-		fs := http.FileServer(http.Dir(c.Site.StaticFilePath))
-		if fs != nil {
-			lastEle := filepath.Base(c.Site.StaticFilePath)
-			mux.Handle("/"+lastEle+"/", http.StripPrefix("/"+lastEle, fs))
-		} else {
-			log.Print("error getting handler for StaticFilePath")
-		}
-	} else {
+	if c.Site.StaticFilePath == "" {
+		return
+	}
+	if err := AddStaticMount(StaticMount{Prefix: staticMountPrefix(c.Site.StaticFilePath), Dir: c.Site.StaticFilePath, Fingerprint: true}); err != nil {
 		log.Printf("error access StaticFilePath: %v", err)
 	}
 }
@@ -0,0 +1,39 @@
+package httpUtil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"tiger/config"
+)
+
+// newTestConfig to get a config with the Dev defaults the tests need, without reading config.json.
+func newTestConfig() *config.Config {
+	c := &config.Config{Env: config.EnvDev}
+	c.Site.Name = "tiger"
+	c.Site.TrailingSlash = TrailingSlashStrict
+	return c
+}
+
+// newTestHandler to reset the package level state and get the handler of c once register has added the url mapping.
+func newTestHandler(t *testing.T, c *config.Config, register func()) http.Handler {
+	t.Helper()
+	Reset()
+	t.Cleanup(Reset)
+	register()
+	return NewHandler(c, nil)
+}
+
+// serveTest to serve one request in memory.
+func serveTest(handler http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func echoPathHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method+" "+r.URL.Path)
+	})
+}
@@ -19,6 +19,22 @@ var onceTemplate sync.Once
 var onceHandler sync.Once
 var mutexMapTemplate sync.RWMutex
 var mapTemplate map[string]*template.Template
+var mutexAsset sync.RWMutex
+var assetFunc = func(name string) string { return name }
+
+// SetAssetFunc to set the func called by the asset template function e.g {{asset "css/app.css"}} to map a static file to its url.
+// httpUtil set it to httpUtil.Asset so the fingerprinted url is emitted. default return the name as it is.
+func SetAssetFunc(fn func(name string) string) {
+	mutexAsset.Lock()
+	defer mutexAsset.Unlock()
+	assetFunc = fn
+}
+
+func asset(name string) string {
+	mutexAsset.RLock()
+	defer mutexAsset.RUnlock()
+	return assetFunc(name)
+}
 
 // GetTemplate to retrieve the template.Template object.
 // template parameter is the full path to the template file where path separator are set to /
@@ -35,6 +51,7 @@ func GetTemplate(template string) (*template.Template, error) {
 
 // AddTemplate to add and parse the template into template.Template object to be reused when call by GetTemplate.
 // template parameter is the full path to the template file where path separator are set to /
+// every template can call the asset function, see SetAssetFunc
 func AddTemplate(template string) (*template.Template, error) {
 	initMapHandler()
 	mutexMapTemplate.Lock()
@@ -53,6 +70,15 @@ func ListTemplate() {
 	}
 }
 
+// Reset to remove every template so the next NewTemplateUtil walk the template folder again. it is meant for tests, see package tigertest.
+func Reset() {
+	mutexMapTemplate.Lock()
+	defer mutexMapTemplate.Unlock()
+	onceTemplate = sync.Once{}
+	mapTemplate = make(map[string]*template.Template)
+	onceHandler.Do(func() {})
+}
+
 // NewTemplateUtil is to walk recursively through the template folder and parse all the template files into template.Template objects. The configuration are from the json attribute called TemplateConfig in config.json.
 func NewTemplateUtil(c *config.Config, db *sql.DB) {
 	onceTemplate.Do(func() { //singleton
@@ -81,7 +107,7 @@ func addToMapHandler(path string) (*template.Template, error) {
 		return nil, err
 	}
 
-	tpl, err := template.New(slashPath).Parse(string(b))
+	tpl, err := template.New(slashPath).Funcs(template.FuncMap{"asset": asset}).Parse(string(b))
 	if err != nil {
 		return nil, err
 	}
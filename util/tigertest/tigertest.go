@@ -0,0 +1,258 @@
+// tigertest is the package for application to test their handlers and url mapping without starting the http server, reading config.json or connecting to MySQL.
+//
+// 	func TestHello(t *testing.T) {
+// 		s := tigertest.New(t, nil, tigertest.NewFakeDB().DB)
+// 		s.Get("/hello2").AssertStatus(http.StatusOK).AssertHeader("Content-Type", "application/json")
+// 		s.Post("/user", User{Name: "tiger"}).AssertStatus(http.StatusCreated).AssertJSON(`{"id":1,"name":"tiger"}`)
+// 	}
+//
+// New reset the package level url mapping, custom error pages, rewrite url and templates so every test start from the same state. tests using New must not run in parallel.
+package tigertest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"tiger/config"
+	httpUtil "tiger/util/http"
+	templateUtil "tiger/util/template"
+)
+
+// Server is the router built from the registered url mapping. requests are served in memory by Handler.
+type Server struct {
+	T       testing.TB
+	Config  *config.Config
+	DB      *sql.DB
+	Handler http.Handler
+}
+
+// Response is the recorded response of a request with assertion helpers. every assertion report through T and return the same Response for chaining.
+type Response struct {
+	*httptest.ResponseRecorder
+	T       testing.TB
+	Request *http.Request
+}
+
+// NewConfig to get an in-memory Config with the same values as the Dev environment of config.json except there is no static file path, database and admin listener.
+func NewConfig() *config.Config {
+	c := &config.Config{Env: config.EnvDev}
+	c.Site.Name = "tiger"
+	c.Site.Url = "localhost"
+	c.Site.Port = 8000
+	c.Site.LogLevel = "info"
+	c.Site.GracefulShutdownSec = 5
+	c.Site.CheckAliveTimeoutSec = 5
+	c.Site.ReadTimeoutSec = 30
+	c.Site.ReadHeaderTimeoutSec = 30
+	c.Site.WriteTimeoutSec = 30
+	c.Site.IdleTimeoutSec = 60
+	c.Site.MaxHeaderBytes = 1000000
+	c.Site.UrlRewrite = true
+	c.Site.TrailingSlash = httpUtil.TrailingSlashRedirect
+	c.Site.CleanPath = true
+	c.TemplateConfig.Path = "templates"
+	c.TemplateConfig.FileExt = ".gohtml"
+	return c
+}
+
+// Reset to clear the package level singletons of httpUtil (mapHandler, rewrite rules, mapCustomHttpError, host patterns, static mounts) and templateUtil (mapTemplate).
+func Reset() {
+	httpUtil.Reset()
+	templateUtil.Reset()
+}
+
+// New to reset the singletons and build a Server from the url mapping registered by the register func.
+// c nil use NewConfig. db can be nil, a FakeDB or a database from OpenDB.
+// register default to the ENTRY POINT httpUtil.RegisterCustomErrorPages and httpUtil.RegisterHandler so the application url mapping are tested as they are served.
+// templates are loaded by templateUtil.NewTemplateUtil when TemplateConfig.Enable is true, the Path is relative to the test working directory.
+// the singletons are reset again when the test end.
+func New(t testing.TB, c *config.Config, db *sql.DB, register ...func(c *config.Config, db *sql.DB)) *Server {
+	t.Helper()
+	if c == nil {
+		c = NewConfig()
+	}
+	if len(register) == 0 {
+		register = []func(c *config.Config, db *sql.DB){httpUtil.RegisterCustomErrorPages, httpUtil.RegisterHandler}
+	}
+	Reset()
+	t.Cleanup(Reset)
+	for _, value := range register {
+		value(c, db)
+	}
+	if c.TemplateConfig.Enable {
+		templateUtil.NewTemplateUtil(c, db)
+	}
+	return &Server{T: t, Config: c, DB: db, Handler: httpUtil.NewHandler(c, db)}
+}
+
+// NewRequest to create a request to the Server. body can be nil, string, []byte, io.Reader, url.Values sent as a form, or any other value sent as json.
+func (s *Server) NewRequest(method string, target string, body interface{}) *http.Request {
+	s.T.Helper()
+	var reader io.Reader
+	contentType := ""
+	switch value := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(value)
+	case []byte:
+		reader = bytes.NewReader(value)
+	case io.Reader:
+		reader = value
+	case url.Values:
+		reader = strings.NewReader(value.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			s.T.Fatalf("error marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+		contentType = "application/json"
+	}
+	r := httptest.NewRequest(method, target, reader)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// Do to serve the request and record the response.
+func (s *Server) Do(r *http.Request) *Response {
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, r)
+	return &Response{ResponseRecorder: w, T: s.T, Request: r}
+}
+
+// Get to serve a GET request.
+func (s *Server) Get(target string) *Response {
+	s.T.Helper()
+	return s.Do(s.NewRequest(http.MethodGet, target, nil))
+}
+
+// Post to serve a POST request. see NewRequest for the body.
+func (s *Server) Post(target string, body interface{}) *Response {
+	s.T.Helper()
+	return s.Do(s.NewRequest(http.MethodPost, target, body))
+}
+
+// Put to serve a PUT request. see NewRequest for the body.
+func (s *Server) Put(target string, body interface{}) *Response {
+	s.T.Helper()
+	return s.Do(s.NewRequest(http.MethodPut, target, body))
+}
+
+// Delete to serve a DELETE request.
+func (s *Server) Delete(target string) *Response {
+	s.T.Helper()
+	return s.Do(s.NewRequest(http.MethodDelete, target, nil))
+}
+
+// AssertStatus to check the response status code.
+func (r *Response) AssertStatus(code int) *Response {
+	r.T.Helper()
+	if r.Code != code {
+		r.T.Errorf("%s %s status got %d want %d body %s", r.Request.Method, r.Request.URL, r.Code, code, r.Body.String())
+	}
+	return r
+}
+
+// AssertHeader to check the response header value. a Content-Type without parameter is compared ignoring its parameters e.g text/html match text/html; charset=utf-8
+func (r *Response) AssertHeader(name string, value string) *Response {
+	r.T.Helper()
+	got := r.Header().Get(name)
+	if got == value {
+		return r
+	}
+	if strings.EqualFold(name, "Content-Type") && !strings.Contains(value, ";") && strings.TrimSpace(strings.Split(got, ";")[0]) == value {
+		return r
+	}
+	r.T.Errorf("%s %s header %s got %q want %q", r.Request.Method, r.Request.URL, name, got, value)
+	return r
+}
+
+// AssertBody to check the whole response body.
+func (r *Response) AssertBody(body string) *Response {
+	r.T.Helper()
+	if got := r.Body.String(); got != body {
+		r.T.Errorf("%s %s body got %q want %q", r.Request.Method, r.Request.URL, got, body)
+	}
+	return r
+}
+
+// AssertBodyContains to check the response body contain the text.
+func (r *Response) AssertBodyContains(text string) *Response {
+	r.T.Helper()
+	if got := r.Body.String(); !strings.Contains(got, text) {
+		r.T.Errorf("%s %s body %q does not contain %q", r.Request.Method, r.Request.URL, got, text)
+	}
+	return r
+}
+
+// AssertJSON to check the response body is the same json as expected ignoring formatting and object key order. expected is a json string, []byte or any value marshalled to json.
+func (r *Response) AssertJSON(expected interface{}) *Response {
+	r.T.Helper()
+	var want []byte
+	switch value := expected.(type) {
+	case string:
+		want = []byte(value)
+	case []byte:
+		want = value
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			r.T.Fatalf("error marshal expected json: %v", err)
+		}
+		want = b
+	}
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(r.Body.Bytes(), &gotValue); err != nil {
+		r.T.Errorf("%s %s body is not json %q: %v", r.Request.Method, r.Request.URL, r.Body.String(), err)
+		return r
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		r.T.Fatalf("expected is not json %q: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		r.T.Errorf("%s %s json got %s want %s", r.Request.Method, r.Request.URL, r.Body.String(), want)
+	}
+	return r
+}
+
+// DecodeJSON to unmarshal the response body into v for further checking. the test is stopped if the body is not json.
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.T.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.T.Fatalf("%s %s body is not json %q: %v", r.Request.Method, r.Request.URL, r.Body.String(), err)
+	}
+	return r
+}
+
+// AssertTemplate to check the response body is the template from templateUtil.GetTemplate rendered with data.
+func (r *Response) AssertTemplate(template string, data interface{}) *Response {
+	r.T.Helper()
+	if want := RenderTemplate(r.T, template, data); r.Body.String() != want {
+		r.T.Errorf("%s %s body got %q want template %s rendered as %q", r.Request.Method, r.Request.URL, r.Body.String(), template, want)
+	}
+	return r
+}
+
+// RenderTemplate to render the template from templateUtil.GetTemplate with data. the test is stopped if the template cannot be found or rendered.
+func RenderTemplate(t testing.TB, template string, data interface{}) string {
+	t.Helper()
+	tpl, err := templateUtil.GetTemplate(template)
+	if err != nil {
+		t.Fatalf("error get template: %v", err)
+	}
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, data); err != nil {
+		t.Fatalf("error render template %s: %v", template, err)
+	}
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package tigertest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeDB is a *sql.DB backed by an in-memory driver. a query return the rows added by AddRows and an exec return the result added by AddResult, any other statement fail.
+// every statement is recorded and can be checked by Statements.
+type FakeDB struct {
+	DB *sql.DB
+
+	mutex     sync.Mutex
+	mapRows   map[string]*fakeRows
+	mapResult map[string]*fakeResult
+	statement []Statement
+}
+
+// Statement is a statement executed on the FakeDB with its arguments.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+type fakeRows struct {
+	Column []string
+	Row    [][]driver.Value
+	index  int
+}
+
+type fakeResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+type fakeConnector struct {
+	db *FakeDB
+}
+
+type fakeConn struct {
+	db *FakeDB
+}
+
+type fakeStmt struct {
+	db    *FakeDB
+	query string
+}
+
+type fakeTx struct{}
+
+// NewFakeDB to get an empty FakeDB.
+func NewFakeDB() *FakeDB {
+	f := &FakeDB{mapRows: make(map[string]*fakeRows), mapResult: make(map[string]*fakeResult)}
+	f.DB = sql.OpenDB(&fakeConnector{db: f})
+	return f
+}
+
+// OpenDB to open a real database for the test e.g an in-memory SQLite and run the schema statements. the database is closed when the test end.
+// the driver must be imported by the test e.g _ "github.com/mattn/go-sqlite3" with OpenDB(t, "sqlite3", ":memory:", "create table user (id integer, name text)")
+func OpenDB(t testing.TB, driverName string, dsn string, schema ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatalf("error open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1) //every connection of an in-memory database is a different database
+	for _, value := range schema {
+		if _, err := db.Exec(value); err != nil {
+			t.Fatalf("error run schema %s: %v", value, err)
+		}
+	}
+	return db
+}
+
+// AddRows to set the rows returned by the query. the query is matched ignoring white space differences. every row must have one value per column.
+// 	Example AddRows("select id, name from user where id = ?", []string{"id", "name"}, []interface{}{1, "tiger"})
+func (f *FakeDB) AddRows(query string, column []string, row ...[]interface{}) {
+	rows := &fakeRows{Column: column}
+	for _, value := range row {
+		var values []driver.Value
+		for _, v := range value {
+			if i, ok := v.(int); ok { //driver.Value only allow int64
+				v = int64(i)
+			}
+			values = append(values, v)
+		}
+		rows.Row = append(rows.Row, values)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.mapRows[normalizeQuery(query)] = rows
+}
+
+// AddResult to set the result returned by the exec of the query e.g insert, update, delete.
+func (f *FakeDB) AddResult(query string, lastInsertId int64, rowsAffected int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.mapResult[normalizeQuery(query)] = &fakeResult{lastInsertId: lastInsertId, rowsAffected: rowsAffected}
+}
+
+// Statements to get every statement executed so far in order.
+func (f *FakeDB) Statements() []Statement {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Statement(nil), f.statement...)
+}
+
+func (f *FakeDB) record(query string, args []driver.Value) {
+	var values []interface{}
+	for _, value := range args {
+		values = append(values, value)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statement = append(f.statement, Statement{Query: query, Args: values})
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: c.db}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c *fakeConnector) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: c.db}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: normalizeQuery(query)}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.record(s.query, args)
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	if result, found := s.db.mapResult[s.query]; found {
+		return result, nil
+	}
+	return nil, errors.New("tigertest: no result added for exec " + s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.record(s.query, args)
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	if rows, found := s.db.mapRows[s.query]; found {
+		return &fakeRows{Column: rows.Column, Row: rows.Row}, nil
+	}
+	return nil, errors.New("tigertest: no rows added for query " + s.query)
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.Column
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.Row) {
+		return io.EOF
+	}
+	copy(dest, r.Row[r.index])
+	r.index++
+	return nil
+}
+
+func (r *fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r *fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func (t *fakeTx) Commit() error {
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package tigertest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"tiger/config"
+	httpUtil "tiger/util/http"
+)
+
+func TestFakeDB(t *testing.T) {
+	f := NewFakeDB()
+	f.AddRows("select id, name from user where id = ?", []string{"id", "name"}, []interface{}{1, "tiger"}, []interface{}{2, "lion"})
+	f.AddResult("insert into user (name) values (?)", 3, 1)
+
+	rows, err := f.DB.Query("select id,   name\n from user where id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []user
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.Id, &u.Name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, u)
+	}
+	rows.Close()
+	if want := []user{{1, "tiger"}, {2, "lion"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows %+v, want %+v", got, want)
+	}
+
+	result, err := f.DB.Exec("insert into user (name) values (?)", "cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := result.LastInsertId(); id != 3 {
+		t.Errorf("LastInsertId = %d, want 3", id)
+	}
+	if count, _ := result.RowsAffected(); count != 1 {
+		t.Errorf("RowsAffected = %d, want 1", count)
+	}
+
+	if _, err := f.DB.Exec("delete from user"); err == nil {
+		t.Error("exec without AddResult did not fail")
+	}
+	if _, err := f.DB.Query("select 1"); err == nil {
+		t.Error("query without AddRows did not fail")
+	}
+
+	want := []Statement{
+		{Query: "select id, name from user where id = ?", Args: []interface{}{int64(1)}},
+		{Query: "insert into user (name) values (?)", Args: []interface{}{"cat"}},
+		{Query: "delete from user"},
+		{Query: "select 1"},
+	}
+	if got := f.Statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statements = %+v, want %+v", got, want)
+	}
+}
+
+func TestFakeDBTx(t *testing.T) {
+	f := NewFakeDB()
+	f.AddResult("update user set name = ?", 0, 2)
+	tx, err := f.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("update user set name = ?", "tiger"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeDBHandler(t *testing.T) {
+	f := NewFakeDB()
+	f.AddRows("select name from user where id = ?", []string{"name"}, []interface{}{"tiger"})
+	s := New(t, nil, f.DB, func(c *config.Config, db *sql.DB) {
+		httpUtil.AddHandlerPlaceholder("/user/{id:int}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var name string
+			if err := db.QueryRow("select name from user where id = ?", httpUtil.PathParam(r, "id")).Scan(&name); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"name": name})
+		}))
+	})
+	s.Get("/user/7").AssertStatus(http.StatusOK).AssertJSON(`{"name":"tiger"}`)
+	if statement := f.Statements(); len(statement) != 1 || statement[0].Args[0] != "7" {
+		t.Errorf("Statements = %+v, want the select with id 7", statement)
+	}
+}
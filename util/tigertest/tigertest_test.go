@@ -0,0 +1,83 @@
+package tigertest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"tiger/config"
+	httpUtil "tiger/util/http"
+)
+
+type user struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func registerUser(c *config.Config, db *sql.DB) {
+	httpUtil.AddHandlerPlaceholder("/user/{id:int}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, `{"name": "tiger", "id": `+httpUtil.PathParam(r, "id")+`}`)
+	}))
+	httpUtil.AddHandler("/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		switch r.Header.Get("Content-Type") {
+		case "application/json":
+			json.NewDecoder(r.Body).Decode(&u)
+		case "application/x-www-form-urlencoded":
+			u.Name = r.FormValue("name")
+		default:
+			b, _ := io.ReadAll(r.Body)
+			u.Name = string(b)
+		}
+		u.Id = 1
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u)
+	}), http.MethodPost)
+}
+
+func TestServer(t *testing.T) {
+	s := New(t, nil, nil, registerUser)
+	s.Get("/user/12").AssertStatus(http.StatusOK).AssertHeader("Content-Type", "application/json").AssertJSON(user{Id: 12, Name: "tiger"})
+	s.Get("/user/abc").AssertStatus(http.StatusNotFound)
+	s.Get("/user/12/").AssertStatus(http.StatusMovedPermanently).AssertHeader("Location", "/user/12")
+	for _, body := range []interface{}{user{Name: "json"}, url.Values{"name": {"form"}}, "text", []byte("bytes")} {
+		var got user
+		s.Post("/user", body).AssertStatus(http.StatusCreated).DecodeJSON(&got)
+		if got.Id != 1 || got.Name == "" {
+			t.Errorf("POST /user %v = %+v", body, got)
+		}
+	}
+	s.Post("/user", "raw").AssertBodyContains(`"name":"raw"`).AssertBody(`{"id":1,"name":"raw"}` + "\n")
+}
+
+func TestNewResetState(t *testing.T) {
+	New(t, nil, nil, registerUser)
+	s := New(t, nil, nil, func(c *config.Config, db *sql.DB) {})
+	s.Get("/user/12").AssertStatus(http.StatusNotFound)
+}
+
+// recordT record the failures of the assertions instead of failing the test.
+type recordT struct {
+	testing.TB
+	failed []string
+}
+
+func (r *recordT) Helper() {}
+
+func (r *recordT) Errorf(format string, args ...interface{}) {
+	r.failed = append(r.failed, format)
+}
+
+func TestAssertFail(t *testing.T) {
+	s := New(t, nil, nil, registerUser)
+	record := &recordT{TB: t}
+	response := s.Get("/user/12")
+	response.T = record
+	response.AssertStatus(http.StatusTeapot).AssertHeader("Content-Type", "text/html").AssertBody("x").AssertBodyContains("lion").AssertJSON(`{"id": 13}`)
+	if len(record.failed) != 5 {
+		t.Errorf("%d assertions failed, want 5", len(record.failed))
+	}
+}